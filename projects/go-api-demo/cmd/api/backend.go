@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/malex1718/go-api-demo/internal/config"
+	"github.com/malex1718/go-api-demo/internal/health"
+	"github.com/malex1718/go-api-demo/internal/metrics"
+	"github.com/malex1718/go-api-demo/internal/middleware"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+// backend es todo lo que depende del pool principal: la API en modo single
+// o las aplicaciones de los tenants y su directorio en modo multi-tenant
+type backend struct {
+	db      *sql.DB
+	server  *apiServer
+	app     *fiber.App
+	tenants *tenantApps
+	lookup  *services.TenantService
+}
+
+// backends sirve las peticiones con el backend actual y lo sustituye por
+// uno nuevo cuando cambian las credenciales de la base de datos. El
+// anterior se cierra pasado ShutdownTimeout, el mismo plazo que tienen las
+// peticiones en curso en el apagado.
+type backends struct {
+	base   apiServer
+	checks *health.Checks
+
+	mu      sync.RWMutex
+	current *backend
+
+	// retiring se cancela en el apagado para cerrar ya los anteriores
+	retiring context.Context
+	stop     context.CancelFunc
+	retired  sync.WaitGroup
+}
+
+func newBackends(base apiServer, checks *health.Checks) *backends {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backends{base: base, checks: checks, retiring: ctx, stop: cancel}
+}
+
+// start conecta con la base de datos al arrancar
+func (b *backends) start(cfg *config.Config) error {
+	next, err := b.build(cfg)
+	if err != nil {
+		return err
+	}
+	b.install(next)
+	return nil
+}
+
+// reload reconstruye el backend si cambiaron las credenciales de la base de
+// datos o de los proveedores OIDC; si falla se sigue con el actual
+func (b *backends) reload(cfg *config.Config) error {
+	previous := b.get().server.cfg
+	if cfg.DatabaseURL == previous.DatabaseURL && reflect.DeepEqual(cfg.OIDCProviders, previous.OIDCProviders) {
+		return nil
+	}
+
+	next, err := b.build(cfg)
+	if err != nil {
+		return err
+	}
+	old := b.install(next)
+	slog.Info("database pool replaced", "drain", cfg.ShutdownTimeout)
+
+	b.retired.Add(1)
+	go func() {
+		defer b.retired.Done()
+		select {
+		case <-time.After(cfg.ShutdownTimeout):
+		case <-b.retiring.Done():
+		}
+		old.close()
+	}()
+	return nil
+}
+
+func (b *backends) build(cfg *config.Config) (*backend, error) {
+	db, err := config.ConnectDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Con tablas compartidas el aislamiento depende de row-level security
+	if cfg.TenancyMode == config.TenancyShared {
+		if err := config.CheckRowSecurity(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	server := b.base
+	server.cfg = cfg
+	server.workers = newWorkers()
+	next := &backend{db: db, server: &server}
+
+	if cfg.TenancyMode == config.TenancySingle {
+		next.app = fiber.New(fiber.Config{
+			ErrorHandler: middleware.ErrorHandler,
+		})
+		server.mount(next.app, db, nil)
+	} else {
+		next.lookup = services.NewTenantService(repository.NewTenantRepository(db), cfg.TenancyMode == config.TenancySchema, tenantCacheTTL)
+		next.tenants = &tenantApps{api: &server, idleTimeout: cfg.TenantIdleTimeout, apps: make(map[int]*tenantApp)}
+		if cfg.TenantIdleTimeout > 0 {
+			server.workers.start(next.tenants.evictIdle)
+		}
+	}
+
+	return next, nil
+}
+
+// install pone next en servicio y devuelve el backend al que sustituye
+func (b *backends) install(next *backend) *backend {
+	metrics.UnregisterDB("main")
+	if err := metrics.RegisterDB(next.db, "main"); err != nil {
+		slog.Warn("database pool metrics not registered", "error", err)
+	}
+	b.checks.Register("database", health.Ping(next.db))
+	b.checks.Register("migrations", health.SchemaVersion(func(ctx context.Context) (int, error) {
+		return repository.CurrentSchemaVersion(ctx, next.db)
+	}, repository.SchemaVersion))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old := b.current
+	b.current = next
+	return old
+}
+
+func (b *backends) get() *backend {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.current
+}
+
+func (b *backends) serve(c *fiber.Ctx) error {
+	current := b.get()
+	if current.tenants != nil {
+		return current.tenants.serve(c)
+	}
+
+	current.app.Handler()(c.Context())
+	return nil
+}
+
+// Resolve busca los tenants en el directorio del backend actual
+func (b *backends) Resolve(ctx context.Context, slug string) (*models.Tenant, error) {
+	current := b.get()
+	if current.lookup == nil {
+		return nil, fmt.Errorf("tenancy mode %s has no tenants", config.TenancySingle)
+	}
+	return current.lookup.Resolve(ctx, slug)
+}
+
+// close cierra en el apagado el backend actual y los que se están retirando
+func (b *backends) close() {
+	b.stop()
+	b.retired.Wait()
+	b.get().close()
+}
+
+// close detiene las tareas en segundo plano y cierra los pools
+func (b *backend) close() {
+	b.server.workers.stop()
+	if b.tenants != nil {
+		b.tenants.close()
+	}
+	b.db.Close()
+}
\ No newline at end of file
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/malex1718/go-api-demo/internal/config"
+)
+
+// configCommand implementa "config print [--redacted]": muestra la
+// configuración efectiva en YAML y sale con error si no es válida
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: api config print [--redacted] [flags]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := flags.Bool("redacted", false, "mask secrets")
+	settings := config.NewFlags(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := settings.Read()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	if err := cfg.Print(os.Stdout, *redacted); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return 1
+	}
+	return 0
+}
\ No newline at end of file
@@ -1,93 +1,682 @@
 package main
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
 	"github.com/malex1718/go-api-demo/internal/config"
 	"github.com/malex1718/go-api-demo/internal/handlers"
+	"github.com/malex1718/go-api-demo/internal/health"
+	"github.com/malex1718/go-api-demo/internal/logging"
+	"github.com/malex1718/go-api-demo/internal/metrics"
 	"github.com/malex1718/go-api-demo/internal/middleware"
+	"github.com/malex1718/go-api-demo/internal/models"
 	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/requestid"
 	"github.com/malex1718/go-api-demo/internal/services"
+	"github.com/malex1718/go-api-demo/internal/signing"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 	// Cargar variables de entorno
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	envErr := godotenv.Load()
+
+	// Subcomandos: config print [--redacted]
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
 	}
 
-	// Configuración
-	cfg := config.Load()
+	// Configuración: valores por defecto, fichero, entorno y flags, en ese
+	// orden de precedencia. No arranca con valores inválidos o inseguros
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	settings := config.NewFlags(flags)
+	flags.Parse(os.Args[1:])
+	cfg, err := settings.Load()
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
 
-	// Conectar a la base de datos
-	db, err := config.ConnectDB(cfg)
+	// Logging estructurado; el nivel se puede cambiar en caliente desde la
+	// API de administración
+	logLevel := new(slog.LevelVar)
+	logLevel.UnmarshalText([]byte(cfg.LogLevel))
+	logger := logging.New(os.Stdout, cfg.LogFormat, logLevel)
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		logger.Info("no .env file found")
+	}
+
+	// Trazas OpenTelemetry; continúan las que llegan en la cabecera
+	// traceparent
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		fatal("failed to configure tracing", err)
 	}
-	defer db.Close()
 
-	// Inicializar repositorios
-	userRepo := repository.NewUserRepository(db)
-	taskRepo := repository.NewTaskRepository(db)
+	passwordService, err := newPasswordService(cfg)
+	if err != nil {
+		fatal("failed to configure password hashing", err)
+	}
 
-	// Inicializar servicios
-	authService := services.NewAuthService(userRepo, cfg.JWTSecret)
-	taskService := services.NewTaskService(taskRepo)
+	// Clave de firma de tokens y enlaces; rota sin reiniciar si viene de
+	// JWT_SECRET_FILE
+	keys := signing.NewKeys([]byte(cfg.JWTSecret))
 
-	// Inicializar handlers
-	authHandler := handlers.NewAuthHandler(authService)
-	taskHandler := handlers.NewTaskHandler(taskService)
+	// Conectar a la base de datos; todo lo que depende del pool se agrupa en
+	// un backend que se reconstruye si cambian las credenciales
+	checks := health.New(cfg.ReadinessTimeout)
+	rateLimits := middleware.NewTenantRateLimiter(cfg.DefaultRateLimit)
+	backends := newBackends(apiServer{passwords: passwordService, logLevel: logLevel, keys: keys, rateLimits: rateLimits}, checks)
+	if err := backends.start(cfg); err != nil {
+		fatal("failed to set up database", err)
+	}
 
 	// Configurar Fiber
 	app := fiber.New(fiber.Config{
-		ErrorHandler: middleware.ErrorHandler,
+		ErrorHandler:          middleware.ErrorHandler,
+		DisableStartupMessage: true,
+		ReadTimeout:           cfg.ReadTimeout,
+		WriteTimeout:          cfg.WriteTimeout,
+		IdleTimeout:           cfg.IdleTimeout,
+		BodyLimit:             cfg.BodyLimit,
 	})
 
 	// Middlewares globales
-	app.Use(logger.New())
+	app.Use(middleware.RequestID())
+	app.Use(middleware.Tracing())
+	app.Use(middleware.RequestLogger(logger))
+	app.Use(middleware.Metrics())
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, HEAD, PUT, PATCH, POST, DELETE",
+		AllowOrigins:  strings.Join(cfg.CORSOrigins, ", "),
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, traceparent, tracestate, " + requestid.Header + ", " + cfg.TenantHeader,
+		AllowMethods:  "GET, HEAD, PUT, PATCH, POST, DELETE",
+		ExposeHeaders: middleware.ImpersonationHeader + ", " + requestid.Header,
 	}))
 
-	// Rate limiting
-	app.Use(middleware.RateLimiter())
+	// Liveness: el proceso responde. /health se mantiene por compatibilidad
+	livez := func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status": "ok",
+			"service": "go-api-demo",
+		})
+	}
+	app.Get("/livez", livez)
+	app.Get("/health", livez)
+
+	// Readiness: dependencias comprobadas con timeout; falla durante el
+	// apagado para que el balanceador deje de enviarnos tráfico
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+		report := checks.Run(c.UserContext())
+		status := fiber.StatusOK
+		if report.Status != health.StatusOK {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(report)
+	})
+
+	// Métricas Prometheus; exponer solo en la red interna
+	if cfg.MetricsEnabled {
+		app.Get("/metrics", adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+	}
+
+	if cfg.TenancyMode == config.TenancySingle {
+		// Rate limiting
+		app.Use(middleware.RateLimiter(cfg.DefaultRateLimit))
+	} else {
+		// Cada petición se resuelve a un tenant, se limita según su cuota
+		// y se delega en la aplicación de ese tenant
+		app.Use(middleware.TenantResolver(middleware.TenantResolverConfig{
+			BaseDomain: cfg.TenantBaseDomain,
+			Header:     cfg.TenantHeader,
+			Lookup:     backends,
+		}))
+		app.Use(rateLimits.Handler)
+	}
+	app.Use(backends.serve)
+
+	// Secretos montados como ficheros (*_FILE): la clave de firma nueva se
+	// usa al momento y las credenciales nuevas de la base de datos
+	// reconstruyen el backend
+	watcher := newWorkers()
+	watcher.start(func(ctx context.Context) {
+		config.WatchSecrets(ctx, cfg, cfg.SecretReloadInterval, func(next *config.Config) {
+			if next.JWTSecret != string(keys.Current()) {
+				keys.Rotate([]byte(next.JWTSecret))
+				logger.Info("signing key rotated")
+			}
+			if err := backends.reload(next); err != nil {
+				logger.Error("database pool not replaced", "error", err)
+			}
+		})
+	})
+
+	// Iniciar servidor
+	go func() {
+		logger.Info("server starting", "port", cfg.Port, "tenancy", cfg.TenancyMode)
+		if err := app.Listen(":" + cfg.Port); err != nil {
+			fatal("server failed to start", err)
+		}
+	}()
+
+	// Apagado ordenado con SIGINT o SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	logger.Info("shutting down", "drain_delay", cfg.ShutdownDrainDelay, "timeout", cfg.ShutdownTimeout)
+
+	// /readyz falla desde ya; se da tiempo al balanceador para que lo vea
+	// antes de dejar de aceptar conexiones
+	checks.Drain()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	// Se esperan las peticiones en curso hasta el plazo; las que sigan
+	// después se cortan
+	if err := app.ShutdownWithTimeout(cfg.ShutdownTimeout); err != nil {
+		logger.Error("in-flight requests cut off", "error", err)
+	}
+
+	// Las tareas en segundo plano terminan su ronda antes de cerrar los pools
+	watcher.stop()
+	backends.close()
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Warn("pending spans lost", "error", err)
+	}
+
+	logger.Info("server stopped")
+}
+
+// fatal registra el error y termina el proceso
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// tenantCacheTTL es lo que tarda en aplicarse la suspensión de un tenant o
+// un cambio de su límite de peticiones
+const tenantCacheTTL = 30 * time.Second
+
+// apiServer monta la API sobre una base de datos. En modo multi-tenant hay
+// una aplicación por tenant, con su propio pool y su clave de firma.
+type apiServer struct {
+	cfg       *config.Config
+	passwords *services.PasswordService
+	logLevel  *slog.LevelVar
+	keys      *signing.Keys
+	workers   *workers
+	// Límites de peticiones por tenant; se olvidan al cerrar un tenant
+	// inactivo
+	rateLimits *middleware.TenantRateLimiter
+}
+
+func (a *apiServer) mount(app *fiber.App, db *sql.DB, tenant *models.Tenant) {
+	keys := a.keys
+	tenantSlug := ""
+	avatarDir := a.cfg.AvatarDir
+	exportDir := a.cfg.ExportDir
+	if tenant != nil {
+		keys = a.keys.Derive(func(master []byte) []byte {
+			return []byte(config.TenantSecret(string(master), tenant.Slug))
+		})
+		tenantSlug = tenant.Slug
+		avatarDir = filepath.Join(a.cfg.AvatarDir, tenant.Slug)
+		exportDir = filepath.Join(a.cfg.ExportDir, tenant.Slug)
+	}
+
+	// La aplicación se sirve desde la principal
+	app.Use(middleware.NestedApp())
+
+	// Plazo de cada petición; config.Load ya validó route_timeouts
+	routeTimeouts, _ := config.ParseRouteTimeouts(a.cfg.RouteTimeouts)
+	app.Use(middleware.Deadline(a.cfg.RequestTimeout, routeTimeouts))
+
+	// Inicializar repositorios
+	userRepo := repository.NewUserRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	attemptRepo := repository.NewLoginAttemptRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	oauthRepo := repository.NewOAuthRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	taskRepo := repository.NewTaskRepository(db)
+	workspaceRepo := repository.NewWorkspaceRepository(db)
+
+	// Inicializar servicios
+	loginThrottle := services.NewLoginThrottle(attemptRepo, auditRepo, services.LockoutPolicy{
+		MaxFailures:       a.cfg.LoginMaxFailures,
+		IPMaxFailures:     a.cfg.LoginIPMaxFailures,
+		BackoffBase:       a.cfg.LoginBackoffBase,
+		MaxBackoff:        a.cfg.LoginMaxBackoff,
+		LockoutDuration:   a.cfg.LoginLockoutDuration,
+		ChallengeLifetime: a.cfg.MFAChallengeLifetime,
+	})
+	lifetimes := services.TokenLifetimes{
+		Access:        a.cfg.AccessTokenLifetime,
+		MFAChallenge:  a.cfg.MFAChallengeLifetime,
+		Impersonation: a.cfg.ImpersonationLifetime,
+		OAuthCode:     a.cfg.OAuthCodeLifetime,
+		OAuthToken:    a.cfg.OAuthTokenLifetime,
+	}
+	authService := services.NewAuthService(userRepo, mfaRepo, loginThrottle, a.passwords, keys, tenantSlug, lifetimes)
+	oidcService := services.NewOIDCService(oidcProviders(a.cfg), userRepo, identityRepo, authService, a.passwords, nil, keys)
+	workspaceService := services.NewWorkspaceService(workspaceRepo, userRepo)
+	taskService := services.NewTaskService(taskRepo, workspaceService)
+	tokenService := services.NewTokenService(tokenRepo)
+	oauthService := services.NewOAuthService(oauthRepo, userRepo, lifetimes)
+	adminService := services.NewAdminService(userRepo, auditRepo, authService, a.passwords)
+	privacyService := services.NewPrivacyService(exportRepo, userRepo, taskRepo, auditRepo, workspaceService, services.ExportPolicy{
+		Dir:         exportDir,
+		Retention:   a.cfg.ExportRetention,
+		LinkTTL:     a.cfg.ExportLinkTTL,
+		DownloadURL: "/api/v1/exports/:id/download",
+		SigningKeys: keys,
+	})
+	accountService := services.NewAccountService(userRepo, authService, a.passwords, privacyService, workspaceService, loginThrottle, services.AccountPolicy{
+		AvatarDir:       avatarDir,
+		AvatarURLPrefix: "/avatars",
+		AvatarMaxBytes:  int64(a.cfg.AvatarMaxBytes),
+		DeletionGrace:   a.cfg.AccountDeletionGrace,
+	})
+
+	// Borrado definitivo de cuentas cuyo periodo de gracia terminó, de
+	// exportaciones caducadas y de intentos de login expirados
+	a.workers.start(func(ctx context.Context) {
+		logger := slog.Default()
+		if tenantSlug != "" {
+			logger = logger.With("tenant", tenantSlug)
+		}
+		accountService.RunPurge(logging.WithContext(ctx, logger), time.Hour)
+	})
+
+	// Tokens opacos: primero PAT, después tokens OAuth si el servidor de
+	// autorización está activo
+	bearerTokens := middleware.Authenticators{tokenService}
+	if a.cfg.OAuthServerEnabled {
+		bearerTokens = append(bearerTokens, oauthService)
+	}
+
+	// Inicializar handlers
+	authHandler := handlers.NewAuthHandler(authService)
+	taskHandler := handlers.NewTaskHandler(taskService)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceService)
+	tokenHandler := handlers.NewTokenHandler(tokenService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, authService)
+	accountHandler := handlers.NewAccountHandler(accountService, int64(a.cfg.AvatarMaxBytes))
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	loggingHandler := handlers.NewLoggingHandler(a.logLevel)
+
+	// Auditoría de peticiones hechas suplantando a un usuario
+	app.Use(middleware.ImpersonationAudit(adminService))
 
 	// Rutas
 	api := app.Group("/api/v1")
 
 	// Rutas de autenticación
 	auth := api.Group("/auth")
-	auth.Post("/register", authHandler.Register)
+	if a.cfg.RegistrationEnabled {
+		auth.Post("/register", authHandler.Register)
+	}
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/mfa/verify", authHandler.VerifyMFA)
+	auth.Get("/oidc/:provider/start", oidcHandler.Start)
+	auth.Get("/oidc/:provider/callback", oidcHandler.Callback)
+
+	// Alta de TOTP (requiere sesión)
+	mfa := auth.Group("/mfa")
+	mfa.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	mfa.Post("/enroll", middleware.RequireScopes(models.ScopeAccountManage), authHandler.EnrollTOTP)
+	mfa.Post("/confirm", middleware.RequireScopes(models.ScopeAccountManage), authHandler.ConfirmTOTP)
+
+	// Tokens de acceso personal
+	tokens := auth.Group("/tokens")
+	tokens.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	tokens.Use(middleware.RequireScopes(models.ScopeAccountManage))
+	tokens.Get("/", tokenHandler.GetTokens)
+	tokens.Post("/", tokenHandler.CreateToken)
+	tokens.Delete("/:id", tokenHandler.RevokeToken)
+
+	// Cuenta del usuario autenticado
+	me := api.Group("/me")
+	me.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	me.Use(middleware.RequireScopes(models.ScopeAccountManage))
+	me.Get("/", authHandler.GetProfile)
+	me.Patch("/", accountHandler.UpdateProfile)
+	me.Post("/password", accountHandler.ChangePassword)
+	me.Post("/avatar", accountHandler.UploadAvatar)
+	me.Delete("/", accountHandler.DeleteAccount)
+	me.Post("/restore", accountHandler.RestoreAccount)
+	me.Post("/export", privacyHandler.RequestExport)
+	me.Get("/export/:id", privacyHandler.GetExport)
+	me.Post("/identities/:provider", oidcHandler.Link)
+
+	// Descarga de exportaciones (enlace firmado, sin sesión)
+	api.Get("/exports/:id/download", privacyHandler.Download)
+
+	// Avatares subidos
+	app.Static("/avatars", avatarDir)
+
+	// Servidor de autorización OAuth2
+	if a.cfg.OAuthServerEnabled {
+		oauth := api.Group("/oauth")
+		oauth.Get("/authorize", oauthHandler.Authorize)
+		oauth.Post("/authorize", oauthHandler.AuthorizeDecision)
+		oauth.Post("/token", oauthHandler.Token)
+		oauth.Post("/introspect", oauthHandler.Introspect)
+		oauth.Post("/revoke", oauthHandler.Revoke)
+
+		// Registro de aplicaciones cliente
+		clients := oauth.Group("/clients")
+		clients.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+		clients.Use(middleware.RequireScopes(models.ScopeAccountManage))
+		clients.Get("/", oauthHandler.GetClients)
+		clients.Post("/", oauthHandler.RegisterClient)
+		clients.Delete("/:id", oauthHandler.DeleteClient)
+	}
+
+	// Administración de usuarios (solo rol admin)
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	admin.Use(middleware.RequireScopes(models.ScopeAccountManage))
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	admin.Get("/users", adminHandler.ListUsers)
+	admin.Patch("/users/:id", adminHandler.UpdateUser)
+	admin.Post("/users/:id/reset-password", adminHandler.ResetPassword)
+	admin.Post("/users/:id/logout", adminHandler.Logout)
+	admin.Post("/users/:id/impersonate", adminHandler.Impersonate)
+
+	// El nivel de log es del proceso: solo lo cambian los administradores
+	// del tenant por defecto
+	if tenant == nil || tenant.Slug == models.DefaultTenantSlug {
+		admin.Get("/log-level", loggingHandler.GetLevel)
+		admin.Put("/log-level", loggingHandler.SetLevel)
+	}
 
 	// Rutas protegidas
 	tasks := api.Group("/tasks")
-	tasks.Use(middleware.AuthMiddleware(cfg.JWTSecret))
-	tasks.Get("/", taskHandler.GetTasks)
-	tasks.Post("/", taskHandler.CreateTask)
-	tasks.Get("/:id", taskHandler.GetTask)
-	tasks.Put("/:id", taskHandler.UpdateTask)
-	tasks.Delete("/:id", taskHandler.DeleteTask)
-
-	// Health check
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-			"service": "go-api-demo",
-		})
+	tasks.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	tasks.Get("/", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetTasks)
+	tasks.Post("/", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.CreateTask)
+	tasks.Get("/assigned", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetAssignedTasks)
+	tasks.Get("/statistics", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetStatistics)
+	tasks.Get("/:id", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetTask)
+	tasks.Put("/:id", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.UpdateTask)
+	tasks.Delete("/:id", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.DeleteTask)
+	tasks.Post("/:id/assign", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.AssignTask)
+	tasks.Get("/:id/assignments", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetAssignments)
+
+	// Espacios de trabajo compartidos; /tasks equivale al espacio personal
+	workspaces := api.Group("/workspaces")
+	workspaces.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	workspaces.Get("/", middleware.RequireScopes(models.ScopeTasksRead), workspaceHandler.GetWorkspaces)
+	workspaces.Post("/", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.CreateWorkspace)
+	workspaces.Get("/:workspaceID", middleware.RequireScopes(models.ScopeTasksRead), workspaceHandler.GetWorkspace)
+	workspaces.Patch("/:workspaceID", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.UpdateWorkspace)
+	workspaces.Delete("/:workspaceID", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.DeleteWorkspace)
+	workspaces.Get("/:workspaceID/members", middleware.RequireScopes(models.ScopeTasksRead), workspaceHandler.GetMembers)
+	workspaces.Patch("/:workspaceID/members/:userID", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.UpdateMember)
+	workspaces.Delete("/:workspaceID/members/:userID", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.RemoveMember)
+	workspaces.Get("/:workspaceID/invitations", middleware.RequireScopes(models.ScopeTasksRead), workspaceHandler.GetInvitations)
+	workspaces.Post("/:workspaceID/invitations", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.Invite)
+	workspaces.Delete("/:workspaceID/invitations/:id", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.RevokeInvitation)
+	workspaces.Get("/:workspaceID/tasks", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetTasks)
+	workspaces.Post("/:workspaceID/tasks", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.CreateTask)
+	workspaces.Get("/:workspaceID/tasks/statistics", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetStatistics)
+	workspaces.Get("/:workspaceID/tasks/:id", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetTask)
+	workspaces.Put("/:workspaceID/tasks/:id", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.UpdateTask)
+	workspaces.Delete("/:workspaceID/tasks/:id", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.DeleteTask)
+	workspaces.Post("/:workspaceID/tasks/:id/assign", middleware.RequireScopes(models.ScopeTasksWrite), taskHandler.AssignTask)
+	workspaces.Get("/:workspaceID/tasks/:id/assignments", middleware.RequireScopes(models.ScopeTasksRead), taskHandler.GetAssignments)
+
+	// Invitaciones recibidas por el usuario autenticado
+	invitations := api.Group("/invitations")
+	invitations.Use(middleware.AuthMiddleware(keys, bearerTokens, authService))
+	invitations.Get("/", middleware.RequireScopes(models.ScopeTasksRead), workspaceHandler.GetMyInvitations)
+	invitations.Post("/:id/accept", middleware.RequireScopes(models.ScopeTasksWrite), workspaceHandler.AcceptInvitation)
+}
+
+// tenantApps crea la aplicación de cada tenant, con su pool de conexiones
+// y sus tareas en segundo plano, en su primera petición, y la cierra cuando
+// lleva idleTimeout sin peticiones
+type tenantApps struct {
+	api         *apiServer
+	idleTimeout time.Duration
+
+	mu     sync.Mutex
+	apps   map[int]*tenantApp
+	closed bool
+}
+
+// tenantApp es la aplicación de un tenant. La construye una sola vez la
+// primera petición que la necesita, fuera del cerrojo de tenantApps, así que
+// un tenant lento de conectar no bloquea a los demás
+type tenantApp struct {
+	slug string
+	once sync.Once
+	app  *fiber.App
+	db   *sql.DB
+	jobs *workers
+	err  error
+
+	// Protegidos por tenantApps.mu
+	inFlight int
+	lastUsed time.Time
+}
+
+func (t *tenantApps) serve(c *fiber.Ctx) error {
+	entry, err := t.acquire(middleware.CurrentTenant(c))
+	if err != nil {
+		return err
+	}
+	defer t.release(entry)
+
+	entry.app.Handler()(c.Context())
+	return nil
+}
+
+// acquire devuelve la aplicación del tenant, construyéndola si hace falta,
+// y la marca en uso hasta release
+func (t *tenantApps) acquire(tenant *models.Tenant) (*tenantApp, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, fiber.ErrServiceUnavailable
+	}
+	entry, ok := t.apps[tenant.ID]
+	if !ok {
+		entry = &tenantApp{slug: tenant.Slug}
+		t.apps[tenant.ID] = entry
+	}
+	entry.inFlight++
+	t.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.err = t.build(entry, tenant)
 	})
+	if entry.err != nil {
+		// Se olvida para que la siguiente petición lo vuelva a intentar
+		t.mu.Lock()
+		entry.inFlight--
+		if t.apps[tenant.ID] == entry {
+			delete(t.apps, tenant.ID)
+		}
+		t.mu.Unlock()
+		return nil, entry.err
+	}
 
-	// Iniciar servidor
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := app.Listen(":" + cfg.Port); err != nil {
-		log.Fatal("Server failed to start:", err)
+	return entry, nil
+}
+
+func (t *tenantApps) release(entry *tenantApp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry.inFlight--
+	entry.lastUsed = time.Now()
+}
+
+func (t *tenantApps) build(entry *tenantApp, tenant *models.Tenant) error {
+	db, err := config.ConnectTenantDB(t.api.cfg, tenant.ID, tenant.SchemaName)
+	if err != nil {
+		return err
+	}
+	// El pool sustituye al del mismo tenant en un backend anterior o al
+	// cerrado por inactividad
+	metrics.UnregisterDB("tenant_" + tenant.Slug)
+	if err := metrics.RegisterDB(db, "tenant_"+tenant.Slug); err != nil {
+		slog.Warn("tenant pool metrics not registered", "tenant", tenant.Slug, "error", err)
+	}
+
+	// Las tareas del tenant se detienen con su aplicación
+	server := *t.api
+	server.workers = newWorkers()
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: middleware.ErrorHandler,
+	})
+	server.mount(app, db, tenant)
+
+	entry.app = app
+	entry.db = db
+	entry.jobs = server.workers
+	return nil
+}
+
+// evictIdle cierra periódicamente las aplicaciones de los tenants sin
+// peticiones en curso ni recientes
+func (t *tenantApps) evictIdle(ctx context.Context) {
+	ticker := time.NewTicker(min(t.idleTimeout, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.mu.Lock()
+			var idle []*tenantApp
+			for id, entry := range t.apps {
+				// Sin peticiones en curso la aplicación ya está construida
+				if entry.inFlight == 0 && now.Sub(entry.lastUsed) >= t.idleTimeout {
+					delete(t.apps, id)
+					metrics.UnregisterDB("tenant_" + entry.slug)
+					t.api.rateLimits.Forget(entry.slug)
+					idle = append(idle, entry)
+				}
+			}
+			t.mu.Unlock()
+
+			for _, entry := range idle {
+				slog.Info("idle tenant closed", "tenant", entry.slug)
+				entry.close()
+			}
+		}
+	}
+}
+
+// close cierra los tenants al retirar el backend
+func (t *tenantApps) close() {
+	t.mu.Lock()
+	t.closed = true
+	apps := t.apps
+	t.apps = nil
+	t.mu.Unlock()
+
+	for _, entry := range apps {
+		entry.close()
+	}
+}
+
+// close detiene las tareas del tenant y cierra su pool. Si la aplicación se
+// está construyendo espera a que termine, y si no llegó a construirse ya no
+// se construye
+func (e *tenantApp) close() {
+	e.once.Do(func() {
+		e.err = fiber.ErrServiceUnavailable
+	})
+	if e.err != nil {
+		return
+	}
+
+	e.jobs.stop()
+	e.db.Close()
+}
+
+// workers ejecuta las tareas en segundo plano y las detiene en el apagado
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+func (w *workers) start(run func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		run(w.ctx)
+	}()
+}
+
+// stop cancela las tareas y espera a que terminen
+func (w *workers) stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+func newPasswordService(cfg *config.Config) (*services.PasswordService, error) {
+	policy := &services.PasswordPolicy{MinLength: cfg.PasswordMinLength}
+	if cfg.BreachedPasswordsFile != "" {
+		list, err := services.NewBreachedPasswordList(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.BreachedList = list
+	}
+
+	argon2id := services.NewArgon2idHasher(uint32(cfg.Argon2Memory), uint32(cfg.Argon2Iterations), uint8(cfg.Argon2Parallelism))
+	bcrypt := services.NewBcryptHasher(cfg.BcryptCost)
+
+	// El algoritmo preferido firma los hashes nuevos; el otro solo verifica
+	// los antiguos hasta que se actualicen en el siguiente login
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id":
+		return services.NewPasswordService(policy, argon2id, bcrypt), nil
+	case "bcrypt":
+		return services.NewPasswordService(policy, bcrypt, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.PasswordHashAlgorithm)
+	}
+}
+
+func oidcProviders(cfg *config.Config) []services.OIDCProviderConfig {
+	providers := make([]services.OIDCProviderConfig, len(cfg.OIDCProviders))
+	for i, p := range cfg.OIDCProviders {
+		providers[i] = services.OIDCProviderConfig(p)
 	}
-}
\ No newline at end of file
+	return providers
+}
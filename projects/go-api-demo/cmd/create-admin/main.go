@@ -0,0 +1,175 @@
+// Command create-admin bootstraps the first administrator. It creates the
+// user, or promotes an existing one, and refuses to run once an admin
+// exists; further admins are managed through the admin API.
+//
+//	ADMIN_PASSWORD=... go run ./cmd/create-admin -username root -email root@example.com
+//
+// Without ADMIN_PASSWORD the password is read from the first line of stdin.
+// In multi-tenant deployments -tenant picks the tenant to bootstrap; each
+// tenant has its own first admin.
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/malex1718/go-api-demo/internal/config"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+func main() {
+	username := flag.String("username", "", "username of the admin")
+	email := flag.String("email", "", "email, required when the user does not exist yet")
+	tenantSlug := flag.String("tenant", "", "tenant to create the admin in (multi-tenant mode)")
+	flag.Parse()
+
+	if *username == "" {
+		log.Fatal("-username is required")
+	}
+
+	// Cargar variables de entorno
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+
+	ctx := context.Background()
+
+	db, err := connectDB(ctx, cfg, *tenantSlug)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	userRepo := repository.NewUserRepository(db)
+
+	exists, err := userRepo.AdminExists(ctx)
+	if err != nil {
+		log.Fatal("Failed to check for admins:", err)
+	}
+	if exists {
+		log.Fatal("An admin already exists; use the admin API to grant the role")
+	}
+
+	// Promover un usuario existente
+	user, err := userRepo.GetByUsername(ctx, *username)
+	if err == nil {
+		if err := userRepo.UpdateRoleAndStatus(ctx, user.ID, models.RoleAdmin, false); err != nil {
+			log.Fatal("Failed to promote user:", err)
+		}
+		fmt.Printf("User %s is now an admin\n", user.Username)
+		return
+	}
+	if err.Error() != "user not found" {
+		log.Fatal("Failed to look up user:", err)
+	}
+
+	if *email == "" {
+		log.Fatal("-email is required to create a new user")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		log.Fatal("Failed to read password:", err)
+	}
+
+	passwords, err := newPasswordService(cfg)
+	if err != nil {
+		log.Fatal("Failed to configure password hashing:", err)
+	}
+	if err := passwords.Validate(*username, password); err != nil {
+		log.Fatal("Password rejected: ", err)
+	}
+	hash, err := passwords.Hash(password)
+	if err != nil {
+		log.Fatal("Failed to hash password:", err)
+	}
+
+	user = &models.User{
+		Username:     *username,
+		Email:        *email,
+		PasswordHash: hash,
+		Role:         models.RoleAdmin,
+	}
+	if err := userRepo.Create(ctx, user); err != nil {
+		log.Fatal("Failed to create admin:", err)
+	}
+
+	fmt.Printf("Admin %s created\n", user.Username)
+}
+
+// connectDB connects to the tenant's data, or to the single-tenant
+// database when no tenant is given.
+func connectDB(ctx context.Context, cfg *config.Config, tenantSlug string) (*sql.DB, error) {
+	if cfg.TenancyMode == config.TenancySingle {
+		if tenantSlug != "" {
+			return nil, fmt.Errorf("-tenant requires TENANCY_MODE %s or %s", config.TenancyShared, config.TenancySchema)
+		}
+		return config.ConnectDB(cfg)
+	}
+
+	if tenantSlug == "" {
+		return nil, fmt.Errorf("-tenant is required in %s tenancy mode", cfg.TenancyMode)
+	}
+
+	registry, err := config.ConnectDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer registry.Close()
+
+	tenant, err := repository.NewTenantRepository(registry).GetBySlug(ctx, tenantSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.ConnectTenantDB(cfg, tenant.ID, tenant.SchemaName)
+}
+
+func readPassword() (string, error) {
+	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// newPasswordService mirrors the API server so the admin's hash uses the
+// configured algorithm and policy.
+func newPasswordService(cfg *config.Config) (*services.PasswordService, error) {
+	policy := &services.PasswordPolicy{MinLength: cfg.PasswordMinLength}
+	if cfg.BreachedPasswordsFile != "" {
+		list, err := services.NewBreachedPasswordList(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.BreachedList = list
+	}
+
+	switch cfg.PasswordHashAlgorithm {
+	case "argon2id":
+		return services.NewPasswordService(policy, services.NewArgon2idHasher(uint32(cfg.Argon2Memory), uint32(cfg.Argon2Iterations), uint8(cfg.Argon2Parallelism))), nil
+	case "bcrypt":
+		return services.NewPasswordService(policy, services.NewBcryptHasher(cfg.BcryptCost)), nil
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.PasswordHashAlgorithm)
+	}
+}
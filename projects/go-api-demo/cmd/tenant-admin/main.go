@@ -0,0 +1,188 @@
+// Command tenant-admin manages the tenants of a multi-tenant deployment.
+//
+//	go run ./cmd/tenant-admin create -slug acme -name "Acme Inc." -max-users 50
+//	go run ./cmd/tenant-admin list
+//	go run ./cmd/tenant-admin suspend -slug acme
+//	go run ./cmd/tenant-admin resume -slug acme
+//	go run ./cmd/tenant-admin limits -slug acme -max-users 100 -max-tasks 5000 -rate-limit 300
+//	go run ./cmd/tenant-admin delete -slug acme -confirm acme
+//
+// In schema-per-tenant mode create builds the tenant's schema from the SQL
+// files in -migrations. Quotas of 0 mean unlimited and a rate limit of 0
+// uses RATE_LIMIT_PER_MINUTE; running servers pick up changes within 30
+// seconds.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/malex1718/go-api-demo/internal/config"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	slug := flags.String("slug", "", "tenant slug, also its subdomain")
+	name := flags.String("name", "", "display name (create)")
+	maxUsers := flags.Int("max-users", 0, "maximum number of users, 0 for unlimited (create, limits)")
+	maxTasks := flags.Int("max-tasks", 0, "maximum number of tasks, 0 for unlimited (create, limits)")
+	rateLimit := flags.Int("rate-limit", 0, "requests per minute and IP, 0 for the default (create, limits)")
+	migrations := flags.String("migrations", "migrations", "directory with the SQL migrations (create, schema mode)")
+	confirm := flags.String("confirm", "", "repeat the slug to confirm (delete)")
+	flags.Parse(args)
+
+	if command != "list" && *slug == "" {
+		log.Fatal("-slug is required")
+	}
+
+	// Cargar variables de entorno
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+	if cfg.TenancyMode == config.TenancySingle {
+		log.Fatalf("TENANCY_MODE must be %s or %s", config.TenancyShared, config.TenancySchema)
+	}
+
+	db, err := config.ConnectDB(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	tenants := services.NewTenantService(repository.NewTenantRepository(db), cfg.TenancyMode == config.TenancySchema, 0)
+	limits := services.TenantLimits{
+		MaxUsers:  quota(*maxUsers),
+		MaxTasks:  quota(*maxTasks),
+		RateLimit: *rateLimit,
+	}
+
+	switch command {
+	case "create":
+		var statements []string
+		if cfg.TenancyMode == config.TenancySchema {
+			statements, err = readMigrations(*migrations)
+			if err != nil {
+				log.Fatal("Failed to read migrations:", err)
+			}
+		}
+
+		tenant, err := tenants.CreateTenant(ctx, services.CreateTenantInput{Slug: *slug, Name: *name, TenantLimits: limits}, statements)
+		if err != nil {
+			log.Fatal("Failed to create tenant:", err)
+		}
+		fmt.Printf("Tenant %s created\n", tenant.Slug)
+
+	case "list":
+		list, err := tenants.ListTenants(ctx)
+		if err != nil {
+			log.Fatal("Failed to list tenants:", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SLUG\tNAME\tSTATUS\tUSERS\tTASKS\tRATE LIMIT")
+		for _, t := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Slug, t.Name, t.Status,
+				quotaUsage(t.UserCount, t.MaxUsers), quotaUsage(t.TaskCount, t.MaxTasks), rate(t.RateLimit))
+		}
+		w.Flush()
+
+	case "suspend":
+		if err := tenants.SuspendTenant(ctx, *slug); err != nil {
+			log.Fatal("Failed to suspend tenant:", err)
+		}
+		fmt.Printf("Tenant %s suspended\n", *slug)
+
+	case "resume":
+		if err := tenants.ResumeTenant(ctx, *slug); err != nil {
+			log.Fatal("Failed to resume tenant:", err)
+		}
+		fmt.Printf("Tenant %s resumed\n", *slug)
+
+	case "limits":
+		if _, err := tenants.UpdateLimits(ctx, *slug, limits); err != nil {
+			log.Fatal("Failed to update limits:", err)
+		}
+		fmt.Printf("Limits of tenant %s updated\n", *slug)
+
+	case "delete":
+		if *confirm != *slug {
+			log.Fatal("Deleting a tenant removes all of its data; pass -confirm with the slug to proceed")
+		}
+		if err := tenants.DeleteTenant(ctx, *slug); err != nil {
+			log.Fatal("Failed to delete tenant:", err)
+		}
+		fmt.Printf("Tenant %s deleted\n", *slug)
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tenant-admin create|list|suspend|resume|limits|delete [flags]")
+	os.Exit(2)
+}
+
+// readMigrations returns the contents of the .sql files in dir, in file
+// name order.
+func readMigrations(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations found in %s", dir)
+	}
+	sort.Strings(files)
+
+	statements := make([]string, len(files))
+	for i, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		statements[i] = string(content)
+	}
+	return statements, nil
+}
+
+func quota(value int) *int {
+	if value <= 0 {
+		return nil
+	}
+	return &value
+}
+
+func quotaUsage(count int, max *int) string {
+	if max == nil {
+		return fmt.Sprintf("%d", count)
+	}
+	return fmt.Sprintf("%d/%d", count, *max)
+}
+
+func rate(limit int) string {
+	if limit == 0 {
+		return "default"
+	}
+	return fmt.Sprintf("%d/min", limit)
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Flags are the command-line overrides of the settings.
+type Flags struct {
+	file      string
+	overrides []override
+}
+
+type override struct {
+	index int
+	value string
+}
+
+// setting is a Config field with a conf tag.
+type setting struct {
+	key    string
+	secret bool
+	index  int
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// NewFlags registers -config and one flag per setting on fs. OIDC
+// providers can only be set in the file or the environment.
+func NewFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.file, "config", "", "YAML or TOML settings file; overrides CONFIG_FILE")
+
+	t := reflect.TypeOf(Config{})
+	for _, s := range settings(t) {
+		s := s
+		fieldType := t.Field(s.index).Type
+		if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct {
+			continue
+		}
+
+		set := func(raw string) error {
+			// parse now so a bad value is reported as a usage error
+			if err := setString(reflect.New(fieldType).Elem(), raw); err != nil {
+				return err
+			}
+			f.overrides = append(f.overrides, override{index: s.index, value: raw})
+			return nil
+		}
+
+		name := strings.ReplaceAll(s.key, "_", "-")
+		usage := "overrides " + strings.ToUpper(s.key)
+		if fieldType.Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, set)
+		} else {
+			fs.Func(name, usage, set)
+		}
+	}
+
+	return f
+}
+
+// Load reads the settings and validates them.
+func Load() (*Config, error) {
+	return (&Flags{}).Load()
+}
+
+// Load reads the settings, with the parsed flags on top, and validates
+// them.
+func (f *Flags) Load() (*Config, error) {
+	cfg, err := f.Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read layers the defaults, the settings file, the environment, including
+// the *_FILE secrets, and the parsed flags without validating the result.
+// Empty environment variables are ignored.
+func (f *Flags) Read() (*Config, error) {
+	cfg := defaults()
+	v := reflect.ValueOf(cfg).Elem()
+
+	file := f.file
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file != "" {
+		if err := readFile(v, file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings(v.Type()) {
+		name := strings.ToUpper(s.key)
+		raw := os.Getenv(name)
+		if raw == "" || name == "OIDC_PROVIDERS" {
+			continue
+		}
+		if err := setString(v.Field(s.index), raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if os.Getenv("OIDC_PROVIDERS") != "" {
+		cfg.OIDCProviders = loadOIDCProviders()
+	}
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, err
+	}
+
+	for _, o := range f.overrides {
+		if err := setString(v.Field(o.index), o.value); err != nil {
+			return nil, err
+		}
+	}
+
+	// JSON for log collectors in production, text for people otherwise
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = "text"
+		if cfg.Environment == EnvironmentProduction {
+			cfg.LogFormat = "json"
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS=corp,google and then
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and
+// _SCOPES for each listed provider.
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		})
+	}
+	return providers
+}
+
+// readFile applies a .yaml, .yml or .toml settings file. Unknown keys are
+// errors, so a typo does not silently leave the default in place.
+func readFile(v reflect.Value, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s: unknown format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	if err := setValues(v, values, ""); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func settings(t reflect.Type) []setting {
+	list := make([]setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("conf")
+		if tag == "" {
+			continue
+		}
+		key, options, _ := strings.Cut(tag, ",")
+		list = append(list, setting{key: key, secret: options == "secret", index: i})
+	}
+	return list
+}
+
+// setValues applies decoded file entries to the struct v; prefix names
+// the enclosing entry in errors.
+func setValues(v reflect.Value, values map[string]any, prefix string) error {
+	index := make(map[string]int)
+	for _, s := range settings(v.Type()) {
+		index[s.key] = s.index
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		i, ok := index[key]
+		if !ok {
+			return fmt.Errorf("%s%s: unknown setting", prefix, key)
+		}
+		if err := setValue(v.Field(i), values[key], prefix+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, value any, name string) error {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("%s: expected a value, got a table", name)
+		}
+		return setValues(v, value, name+".")
+	case []map[string]any:
+		items := make([]any, len(value))
+		for i, item := range value {
+			items[i] = item
+		}
+		return setValue(v, items, name)
+	case []any:
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("%s: expected a value, got a list", name)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(value), len(value))
+		for i, item := range value {
+			if err := setValue(slice.Index(i), item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	default:
+		if err := setString(v, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+}
+
+// setString parses raw into v. Lists are comma-separated.
+func setString(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("expected a list, got %q", raw)
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+
+	"github.com/malex1718/go-api-demo/internal/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Print writes the settings as a YAML file that Load accepts. With
+// redacted, secrets are masked; database URLs keep everything but the
+// password.
+func (c *Config) Print(w io.Writer, redacted bool) error {
+	doc := printStruct(reflect.ValueOf(c).Elem(), redacted)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// printStruct renders v as a mapping in field order.
+func printStruct(v reflect.Value, redacted bool) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range settings(v.Type()) {
+		value := printValue(v.Field(s.index), redacted)
+		if redacted && s.secret {
+			value = scalar(redact(v.Field(s.index).String()))
+		}
+		node.Content = append(node.Content, scalar(s.key), value)
+	}
+	return node
+}
+
+func printValue(v reflect.Value, redacted bool) *yaml.Node {
+	if v.Type() == durationType {
+		return scalar(v.Interface().(fmt.Stringer).String())
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return printStruct(v, redacted)
+	case reflect.Slice:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			node.Content = append(node.Content, printValue(v.Index(i), redacted))
+		}
+		return node
+	case reflect.String:
+		return scalar(v.String())
+	case reflect.Int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatInt(v.Int(), 10)}
+	case reflect.Float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatFloat(v.Float(), 'g', -1, 64)}
+	case reflect.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatBool(v.Bool())}
+	default:
+		return scalar(fmt.Sprint(v.Interface()))
+	}
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// redact masks a secret, leaving empty values empty so a missing secret
+// still shows.
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return logging.Redacted
+}
\ No newline at end of file
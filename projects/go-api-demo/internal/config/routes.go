@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RouteTimeout is the deadline of the requests to the routes matching
+// Method and Path. An empty Method matches any method; in Path a :param
+// segment matches any one segment and a final * the rest of the path.
+type RouteTimeout struct {
+	Method  string
+	Path    string
+	Timeout time.Duration
+}
+
+// ParseRouteTimeouts parses route_timeouts entries such as
+// "GET /api/v1/tasks/statistics=30s" or "/api/v1/me/export=0", zero
+// meaning no deadline.
+func ParseRouteTimeouts(entries []string) ([]RouteTimeout, error) {
+	routes := make([]RouteTimeout, 0, len(entries))
+	for _, entry := range entries {
+		route, raw, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: expected [METHOD] PATH=DURATION", entry)
+		}
+
+		var r RouteTimeout
+		fields := strings.Fields(route)
+		switch len(fields) {
+		case 1:
+			r.Path = fields[0]
+		case 2:
+			r.Method, r.Path = strings.ToUpper(fields[0]), fields[1]
+		default:
+			return nil, fmt.Errorf("%q: expected [METHOD] PATH=DURATION", entry)
+		}
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("%q: the path must start with /", entry)
+		}
+
+		timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		if timeout < 0 {
+			return nil, fmt.Errorf("%q: the timeout must not be negative", entry)
+		}
+		r.Timeout = timeout
+
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// Matches reports whether a request for method and path is to the route.
+func (r RouteTimeout) Matches(method, path string) bool {
+	if r.Method != "" && r.Method != method && !(r.Method == http.MethodGet && method == http.MethodHead) {
+		return false
+	}
+
+	pattern := strings.Split(strings.Trim(r.Path, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range pattern {
+		if p == "*" && i == len(pattern)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if p != segments[i] && !(strings.HasPrefix(p, ":") && segments[i] != "") {
+			return false
+		}
+	}
+	return len(segments) == len(pattern)
+}
\ No newline at end of file
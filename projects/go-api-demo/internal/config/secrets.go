@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// secretFile is a secret read from the file named by <name>_FILE.
+type secretFile struct {
+	name  string
+	path  string
+	value string
+	apply func(cfg *Config, value string)
+}
+
+// readSecretFiles applies JWT_SECRET_FILE, DATABASE_URL_FILE and the other
+// *_FILE variables of secret settings, including
+// OIDC_<NAME>_CLIENT_SECRET_FILE for each OIDC provider.
+func (c *Config) readSecretFiles() error {
+	for _, s := range settings(reflect.TypeOf(*c)) {
+		if !s.secret {
+			continue
+		}
+		index := s.index
+		err := c.addSecretFile(strings.ToUpper(s.key), func(cfg *Config, value string) {
+			reflect.ValueOf(cfg).Elem().Field(index).SetString(value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, p := range c.OIDCProviders {
+		i := i
+		err := c.addSecretFile("OIDC_"+strings.ToUpper(p.Name)+"_CLIENT_SECRET", func(cfg *Config, value string) {
+			providers := append([]OIDCProviderConfig(nil), cfg.OIDCProviders...)
+			providers[i].ClientSecret = value
+			cfg.OIDCProviders = providers
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) addSecretFile(name string, apply func(cfg *Config, value string)) error {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return nil
+	}
+	if os.Getenv(name) != "" {
+		return fmt.Errorf("%s and %s_FILE are both set", name, name)
+	}
+
+	value, err := readSecret(path)
+	if err != nil {
+		return fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	if value == "" {
+		return fmt.Errorf("%s_FILE: %s is empty", name, path)
+	}
+
+	apply(c, value)
+	c.secretFiles = append(c.secretFiles, secretFile{name: name, path: path, value: value, apply: apply})
+	return nil
+}
+
+// WatchSecrets re-reads the *_FILE secrets of cfg every interval until ctx
+// is done. When any changed and the result still validates, reload gets a
+// copy of the settings with the new values. Polling also sees Kubernetes
+// updating a mounted secret by swapping a symlink.
+func WatchSecrets(ctx context.Context, cfg *Config, interval time.Duration, reload func(next *Config)) {
+	if len(cfg.secretFiles) == 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	current := cfg
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, changed := current.rereadSecrets()
+		if !changed {
+			continue
+		}
+		if err := next.Validate(); err != nil {
+			slog.Error("rotated secrets rejected", "error", err)
+			continue
+		}
+
+		reload(next)
+		current = next
+	}
+}
+
+// rereadSecrets returns a copy of c with the current contents of its secret
+// files. Unreadable or empty files, as while a secret is being replaced,
+// keep the previous value.
+func (c *Config) rereadSecrets() (*Config, bool) {
+	next := *c
+	next.secretFiles = make([]secretFile, len(c.secretFiles))
+
+	changed := false
+	for i, f := range c.secretFiles {
+		value, err := readSecret(f.path)
+		if err != nil {
+			slog.Warn("secret file unreadable", "variable", f.name+"_FILE", "error", err)
+		} else if value != "" && value != f.value {
+			f.apply(&next, value)
+			f.value = value
+			changed = true
+			slog.Info("secret file changed", "variable", f.name+"_FILE")
+		}
+		next.secretFiles[i] = f
+	}
+
+	return &next, changed
+}
+
+// readSecret reads a secret file, dropping the trailing newline most
+// editors and echo add.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
\ No newline at end of file
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+// minSecretLength is the shortest JWT secret accepted in production.
+const minSecretLength = 32
+
+// Validate reports every invalid setting at once. In production it also
+// refuses the development database URL and default or short secrets.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(oneOf(c.Environment, EnvironmentDevelopment, EnvironmentProduction),
+		"app_env: must be %s or %s, got %q", EnvironmentDevelopment, EnvironmentProduction, c.Environment)
+	check(oneOf(c.LogFormat, "json", "text"), "log_format: must be json or text, got %q", c.LogFormat)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "log_level: unknown level %q", c.LogLevel)
+	check(c.Port != "", "port: must be set")
+	check(c.SecretReloadInterval >= 0, "secret_reload_interval: must not be negative")
+
+	check(c.ReadTimeout >= 0 && c.WriteTimeout >= 0 && c.IdleTimeout >= 0,
+		"read_timeout, write_timeout, idle_timeout: must not be negative")
+	check(c.BodyLimit >= c.AvatarMaxBytes, "body_limit: must be at least avatar_max_bytes (%d)", c.AvatarMaxBytes)
+	check(len(c.CORSOrigins) > 0, "cors_origins: must list at least one origin")
+	check(c.RequestTimeout >= 0, "request_timeout: must not be negative")
+	if _, err := ParseRouteTimeouts(c.RouteTimeouts); err != nil {
+		errs = append(errs, fmt.Errorf("route_timeouts: %w", err))
+	}
+	check(c.DBMaxOpenConns >= 0, "db_max_open_conns: must not be negative")
+	check(c.DBMaxIdleConns >= 0, "db_max_idle_conns: must not be negative")
+	check(oneOf(c.DBDriver, DriverPQ, DriverPgx), "db_driver: must be %s or %s, got %q", DriverPQ, DriverPgx, c.DBDriver)
+	check(c.DBConnMaxLifetime >= 0 && c.DBConnMaxIdleTime >= 0 && c.DBStatementTimeout >= 0 && c.DBStartupTimeout >= 0,
+		"db_conn_max_lifetime, db_conn_max_idle_time, db_statement_timeout, db_startup_timeout: must not be negative")
+
+	check(oneOf(c.TracingExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP),
+		"tracing_exporter: must be %s, %s or %s, got %q", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.TracingExporter)
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1,
+		"tracing_sample_ratio: must be between 0 and 1, got %v", c.TracingSampleRatio)
+
+	check(oneOf(c.TenancyMode, TenancySingle, TenancyShared, TenancySchema),
+		"tenancy_mode: must be %s, %s or %s, got %q", TenancySingle, TenancyShared, TenancySchema, c.TenancyMode)
+	check(c.DefaultRateLimit > 0, "rate_limit_per_minute: must be positive")
+	check(c.TenantDBMaxOpenConns > 0, "tenant_db_max_open_conns: must be positive")
+	check(c.TenantIdleTimeout >= 0, "tenant_idle_timeout: must not be negative")
+
+	check(c.AccessTokenLifetime > 0, "access_token_lifetime: must be positive")
+	check(c.MFAChallengeLifetime > 0, "mfa_challenge_lifetime: must be positive")
+	check(c.ImpersonationLifetime > 0, "impersonation_lifetime: must be positive")
+	check(c.OAuthCodeLifetime > 0, "oauth_code_lifetime: must be positive")
+	check(c.OAuthTokenLifetime > 0, "oauth_token_lifetime: must be positive")
+
+	check(oneOf(c.PasswordHashAlgorithm, "argon2id", "bcrypt"),
+		"password_hash_algorithm: must be argon2id or bcrypt, got %q", c.PasswordHashAlgorithm)
+
+	for i, p := range c.OIDCProviders {
+		check(p.Name != "" && p.IssuerURL != "" && p.ClientID != "",
+			"oidc_providers[%d]: name, issuer_url and client_id are required", i)
+	}
+
+	if c.Environment == EnvironmentProduction {
+		check(c.JWTSecret != defaultJWTSecret, "jwt_secret: the default secret is not allowed in production")
+		check(len(c.JWTSecret) >= minSecretLength,
+			"jwt_secret: must be at least %d characters in production", minSecretLength)
+		check(c.DatabaseURL != defaultDatabaseURL, "database_url: the default URL is not allowed in production")
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
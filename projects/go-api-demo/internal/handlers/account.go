@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+type AccountHandler struct {
+	accountService *services.AccountService
+	validator      *validator.Validate
+	avatarMaxBytes int64
+}
+
+func NewAccountHandler(accountService *services.AccountService, avatarMaxBytes int64) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		validator:      validator.New(),
+		avatarMaxBytes: avatarMaxBytes,
+	}
+}
+
+func (h *AccountHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.UpdateProfileInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.accountService.UpdateProfile(r.Context(), userID, input)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "username already taken", "email already registered":
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, profile, http.StatusOK)
+}
+
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.ChangePasswordInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	err := h.accountService.ChangePassword(r.Context(), userID, input, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "invalid current password":
+			statusCode = http.StatusUnauthorized
+		case "too many failed attempts":
+			statusCode = http.StatusTooManyRequests
+		case "password too short", "password must not contain the username", "password appears in a known data breach":
+			statusCode = http.StatusBadRequest
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Password changed successfully",
+	}, http.StatusOK)
+}
+
+func (h *AccountHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Leave room for the multipart envelope around the file
+	r.Body = http.MaxBytesReader(w, r.Body, h.avatarMaxBytes+64<<10)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		h.respondError(w, "avatar file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	profile, err := h.accountService.UpdateAvatar(r.Context(), userID, file)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "avatar too large":
+			statusCode = http.StatusRequestEntityTooLarge
+		case "unsupported avatar format":
+			statusCode = http.StatusUnsupportedMediaType
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, profile, http.StatusOK)
+}
+
+func (h *AccountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.DeleteAccountInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.accountService.RequestDeletion(r.Context(), userID, input, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "invalid credentials", "mfa code required", "invalid mfa code":
+			statusCode = http.StatusUnauthorized
+		case "too many failed attempts":
+			statusCode = http.StatusTooManyRequests
+		case "last owner of a shared workspace":
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, profile, http.StatusAccepted)
+}
+
+func (h *AccountHandler) RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	profile, err := h.accountService.CancelDeletion(r.Context(), userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "account is not scheduled for deletion":
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, profile, http.StatusOK)
+}
+
+func (h *AccountHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *AccountHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
+
+func (h *AccountHandler) formatValidationError(err error) string {
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, e := range validationErrors {
+			switch e.Tag() {
+			case "required":
+				return e.Field() + " is required"
+			case "email":
+				return "Invalid email format"
+			case "min":
+				if e.Field() == "NewPassword" {
+					return "Password must be at least 8 characters"
+				}
+				return e.Field() + " is too short"
+			case "max":
+				return e.Field() + " is too long"
+			}
+		}
+	}
+	return "Validation error"
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+type AdminHandler struct {
+	adminService *services.AdminService
+	validator    *validator.Validate
+}
+
+func NewAdminHandler(adminService *services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		validator:    validator.New(),
+	}
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+	users, err := h.adminService.ListUsers(r.Context(), services.ListUsersInput{
+		Search:  query.Get("q"),
+		Page:    page,
+		PerPage: perPage,
+	})
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, users, http.StatusOK)
+}
+
+func (h *AdminHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.UpdateUserInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, "Role must be one of: admin, user", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.UpdateUser(r.Context(), adminID, userID, input, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "cannot change your own role or status":
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, user, http.StatusOK)
+}
+
+func (h *AdminHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	reset, err := h.adminService.ForcePasswordReset(r.Context(), adminID, userID, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			statusCode = http.StatusNotFound
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, reset, http.StatusOK)
+}
+
+func (h *AdminHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.adminService.ForceLogout(r.Context(), adminID, userID, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "user not found" {
+			statusCode = http.StatusNotFound
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "User sessions revoked",
+	}, http.StatusOK)
+}
+
+func (h *AdminHandler) Impersonate(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional; an empty one means read-only
+	var input services.ImpersonateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	impersonation, err := h.adminService.Impersonate(r.Context(), adminID, userID, input, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "user not found":
+			statusCode = http.StatusNotFound
+		case "cannot impersonate yourself", "cannot impersonate an admin", "account disabled":
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, impersonation, http.StatusOK)
+}
+
+func (h *AdminHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *AdminHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
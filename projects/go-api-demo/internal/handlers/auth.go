@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
@@ -34,11 +35,16 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register user
-	authResponse, err := h.authService.Register(input)
+	authResponse, err := h.authService.Register(r.Context(), input)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "username already taken" || err.Error() == "email already registered" {
+		switch err.Error() {
+		case "username already taken", "email already registered":
 			statusCode = http.StatusConflict
+		case "password too short", "password must not contain the username", "password appears in a known data breach":
+			statusCode = http.StatusBadRequest
+		case "user quota exceeded":
+			statusCode = http.StatusForbidden
 		}
 		h.respondError(w, err.Error(), statusCode)
 		return
@@ -61,11 +67,16 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Login user
-	authResponse, err := h.authService.Login(input)
+	authResponse, err := h.authService.Login(r.Context(), input, clientIP(r))
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "invalid credentials" {
+		switch err.Error() {
+		case "invalid credentials":
 			statusCode = http.StatusUnauthorized
+		case "account disabled":
+			statusCode = http.StatusForbidden
+		case "too many failed attempts":
+			statusCode = http.StatusTooManyRequests
 		}
 		h.respondError(w, err.Error(), statusCode)
 		return
@@ -82,7 +93,7 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.authService.GetUserByID(userID)
+	user, err := h.authService.GetUserByID(r.Context(), userID)
 	if err != nil {
 		h.respondError(w, "User not found", http.StatusNotFound)
 		return
@@ -91,6 +102,92 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, user, http.StatusOK)
 }
 
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var input services.VerifyMFAInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	authResponse, err := h.authService.VerifyMFA(r.Context(), input, clientIP(r))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "invalid mfa code", "invalid or expired challenge":
+			statusCode = http.StatusUnauthorized
+		case "too many failed attempts":
+			statusCode = http.StatusTooManyRequests
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, authResponse, http.StatusOK)
+}
+
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "mfa already enabled" {
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, enrollment, http.StatusOK)
+}
+
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.ConfirmTOTPInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.authService.ConfirmTOTP(r.Context(), userID, input)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "invalid mfa code":
+			statusCode = http.StatusUnauthorized
+		case "mfa already enabled":
+			statusCode = http.StatusConflict
+		case "mfa enrollment not started":
+			statusCode = http.StatusBadRequest
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, codes, http.StatusOK)
+}
+
 func (h *AuthHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -105,6 +202,14 @@ func (h *AuthHandler) respondError(w http.ResponseWriter, message string, status
 	})
 }
 
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (h *AuthHandler) formatValidationError(err error) string {
 	if validationErrors, ok := err.(validator.ValidationErrors); ok {
 		for _, e := range validationErrors {
@@ -120,6 +225,10 @@ func (h *AuthHandler) formatValidationError(err error) string {
 				return e.Field() + " is too short"
 			case "max":
 				return e.Field() + " is too long"
+			case "len", "numeric":
+				return e.Field() + " must be a 6-digit code"
+			case "oneof":
+				return "Scopes must be one of: tasks:read, tasks:write, account:manage"
 			}
 		}
 	}
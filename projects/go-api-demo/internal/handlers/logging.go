@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/malex1718/go-api-demo/internal/logging"
+)
+
+// LoggingHandler reads and changes the log level of the running server.
+type LoggingHandler struct {
+	level *slog.LevelVar
+}
+
+func NewLoggingHandler(level *slog.LevelVar) *LoggingHandler {
+	return &LoggingHandler{level: level}
+}
+
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func (h *LoggingHandler) GetLevel(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, logLevelResponse{Level: strings.ToLower(h.level.Level().String())}, http.StatusOK)
+}
+
+// SetLevel takes effect immediately and lasts until the next restart,
+// which goes back to LOG_LEVEL.
+func (h *LoggingHandler) SetLevel(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input logLevelResponse
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(input.Level)); err != nil {
+		h.respondError(w, "Level must be one of: debug, info, warn, error", http.StatusBadRequest)
+		return
+	}
+
+	previous := h.level.Level()
+	h.level.Set(level)
+	logging.FromContext(r.Context()).Warn("log level changed",
+		slog.Int("admin_id", adminID),
+		slog.String("from", previous.String()),
+		slog.String("to", level.String()),
+	)
+
+	h.respondJSON(w, logLevelResponse{Level: strings.ToLower(level.String())}, http.StatusOK)
+}
+
+func (h *LoggingHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *LoggingHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Authorize {{.ClientName}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; color: #1f2937; }
+label { display: block; margin-top: .75rem; font-size: .875rem; }
+input { width: 100%; padding: .5rem; box-sizing: border-box; }
+.error { color: #b91c1c; }
+.actions { display: flex; gap: .5rem; margin-top: 1.25rem; }
+button { flex: 1; padding: .6rem; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>{{.ClientName}}</h1>
+<p>wants to access your account and will be able to:</p>
+<ul>
+{{range .Scopes}}<li><strong>{{.Description}}</strong> <code>{{.Name}}</code></li>
+{{end}}</ul>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<label>Username <input name="username" autocomplete="username" required></label>
+<label>Password <input name="password" type="password" autocomplete="current-password" required></label>
+<label>Authenticator or recovery code (if enabled) <input name="mfa_code" autocomplete="one-time-code"></label>
+<div class="actions">
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+<button type="submit" name="decision" value="allow">Allow</button>
+</div>
+</form>
+</body>
+</html>
+`))
+
+type consentPage struct {
+	*services.ConsentDetails
+	Request services.AuthorizationRequest
+	Error   string
+}
+
+type OAuthHandler struct {
+	oauthService *services.OAuthService
+	authService  *services.AuthService
+	validator    *validator.Validate
+}
+
+func NewOAuthHandler(oauthService *services.OAuthService, authService *services.AuthService) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		authService:  authService,
+		validator:    validator.New(),
+	}
+}
+
+func (h *OAuthHandler) RegisterClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.RegisterClientInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	client, err := h.oauthService.RegisterClient(r.Context(), userID, input)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "public clients need at least one redirect URI" {
+			statusCode = http.StatusBadRequest
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, client, http.StatusCreated)
+}
+
+func (h *OAuthHandler) GetClients(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	clients, err := h.oauthService.GetUserClients(r.Context(), userID)
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, clients, http.StatusOK)
+}
+
+func (h *OAuthHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	clientID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid client ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.oauthService.DeleteClient(r.Context(), clientID, userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "client not found or unauthorized" {
+			statusCode = http.StatusNotFound
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Client deleted successfully",
+	}, http.StatusOK)
+}
+
+// Authorize renders the consent screen for an authorization-code request.
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	req := authorizationRequest(r)
+
+	details, err := h.oauthService.ValidateAuthorizationRequest(r.Context(), req)
+	if err != nil {
+		h.authorizeError(w, r, req, err)
+		return
+	}
+
+	h.renderConsent(w, consentPage{ConsentDetails: details, Request: req}, http.StatusOK)
+}
+
+// AuthorizeDecision handles the consent form: it authenticates the user
+// through AuthService and redirects back to the client.
+func (h *OAuthHandler) AuthorizeDecision(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req := authorizationRequest(r)
+
+	details, err := h.oauthService.ValidateAuthorizationRequest(r.Context(), req)
+	if err != nil {
+		h.authorizeError(w, r, req, err)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "allow" {
+		http.Redirect(w, r, h.oauthService.ErrorRedirect(req, "access_denied"), http.StatusFound)
+		return
+	}
+
+	user, err := h.authService.AuthenticateUser(
+		r.Context(),
+		r.PostForm.Get("username"),
+		r.PostForm.Get("password"),
+		r.PostForm.Get("mfa_code"),
+		clientIP(r),
+	)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		message := "Something went wrong, please try again"
+		switch err.Error() {
+		case "invalid credentials":
+			statusCode, message = http.StatusUnauthorized, "Invalid username or password"
+		case "mfa code required":
+			statusCode, message = http.StatusUnauthorized, "This account requires an authenticator code"
+		case "invalid mfa code":
+			statusCode, message = http.StatusUnauthorized, "Invalid authenticator code"
+		case "account disabled":
+			statusCode, message = http.StatusForbidden, "This account is disabled"
+		case "too many failed attempts":
+			statusCode, message = http.StatusTooManyRequests, "Too many failed attempts, try again later"
+		}
+		h.renderConsent(w, consentPage{ConsentDetails: details, Request: req, Error: message}, statusCode)
+		return
+	}
+
+	redirect, err := h.oauthService.Approve(r.Context(), req, user.ID)
+	if err != nil {
+		h.authorizeError(w, r, req, err)
+		return
+	}
+
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.respondOAuthError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret := clientCredentials(r)
+
+	var token *services.OAuthTokenResponse
+	var err error
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		token, err = h.oauthService.ExchangeAuthorizationCode(
+			r.Context(),
+			clientID,
+			clientSecret,
+			r.PostForm.Get("code"),
+			r.PostForm.Get("redirect_uri"),
+			r.PostForm.Get("code_verifier"),
+		)
+	case "client_credentials":
+		token, err = h.oauthService.ClientCredentials(r.Context(), clientID, clientSecret, r.PostForm.Get("scope"))
+	default:
+		h.respondOAuthError(w, "unsupported_grant_type")
+		return
+	}
+
+	if err != nil {
+		h.respondOAuthError(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, token, http.StatusOK)
+}
+
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.respondOAuthError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret := clientCredentials(r)
+
+	introspection, err := h.oauthService.Introspect(r.Context(), clientID, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		h.respondOAuthError(w, err.Error())
+		return
+	}
+
+	h.respondJSON(w, introspection, http.StatusOK)
+}
+
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.respondOAuthError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret := clientCredentials(r)
+
+	if err := h.oauthService.Revoke(r.Context(), clientID, clientSecret, r.PostForm.Get("token")); err != nil {
+		h.respondOAuthError(w, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *OAuthHandler) authorizeError(w http.ResponseWriter, r *http.Request, req services.AuthorizationRequest, err error) {
+	switch err.Error() {
+	case "invalid_client":
+		h.respondError(w, "Unknown client", http.StatusBadRequest)
+	case "invalid_redirect_uri":
+		h.respondError(w, "Redirect URI is not registered for this client", http.StatusBadRequest)
+	case "invalid_request", "invalid_scope", "unsupported_response_type":
+		http.Redirect(w, r, h.oauthService.ErrorRedirect(req, err.Error()), http.StatusFound)
+	default:
+		http.Redirect(w, r, h.oauthService.ErrorRedirect(req, "server_error"), http.StatusFound)
+	}
+}
+
+func (h *OAuthHandler) renderConsent(w http.ResponseWriter, page consentPage, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The consent screen must never be framed (clickjacking)
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(statusCode)
+	consentTemplate.Execute(w, page)
+}
+
+func authorizationRequest(r *http.Request) services.AuthorizationRequest {
+	return services.AuthorizationRequest{
+		ResponseType:        r.FormValue("response_type"),
+		ClientID:            r.FormValue("client_id"),
+		RedirectURI:         r.FormValue("redirect_uri"),
+		Scope:               r.FormValue("scope"),
+		State:               r.FormValue("state"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+	}
+}
+
+// clientCredentials reads HTTP Basic client authentication, falling back
+// to client_id/client_secret form fields (RFC 6749 section 2.3.1).
+func clientCredentials(r *http.Request) (string, string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		return id, secret
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
+
+func (h *OAuthHandler) respondOAuthError(w http.ResponseWriter, code string) {
+	statusCode := http.StatusBadRequest
+	switch code {
+	case "invalid_client":
+		statusCode = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	case "invalid_grant", "invalid_request", "invalid_scope", "unauthorized_client", "unsupported_grant_type":
+	default:
+		statusCode = http.StatusInternalServerError
+		code = "server_error"
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.respondJSON(w, map[string]string{"error": code}, statusCode)
+}
+
+func (h *OAuthHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *OAuthHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
+
+func (h *OAuthHandler) formatValidationError(err error) string {
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, e := range validationErrors {
+			switch e.Tag() {
+			case "required":
+				return e.Field() + " is required"
+			case "min":
+				return e.Field() + " is too short"
+			case "max":
+				return e.Field() + " is too long"
+			case "url":
+				return "Redirect URIs must be absolute URLs"
+			case "oneof":
+				return "Scopes must be one of: tasks:read, tasks:write"
+			}
+		}
+	}
+	return "Validation error"
+}
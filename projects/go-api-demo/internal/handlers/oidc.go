@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+func (h *OIDCHandler) Start(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	start, err := h.oidcService.StartLogin(r.Context(), vars["provider"])
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "unknown identity provider":
+			statusCode = http.StatusNotFound
+		case "identity provider unavailable":
+			statusCode = http.StatusBadGateway
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.setStateCookie(w, r, start.StateCookie)
+	http.Redirect(w, r, start.AuthURL, http.StatusFound)
+}
+
+// Link starts linking an identity to the signed-in user. It answers with
+// the provider URL instead of redirecting, as it is called with a bearer
+// token; the browser then follows the URL and returns to Callback.
+func (h *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	start, err := h.oidcService.StartLink(r.Context(), vars["provider"], userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "unknown identity provider":
+			statusCode = http.StatusNotFound
+		case "account disabled":
+			statusCode = http.StatusForbidden
+		case "identity provider unavailable":
+			statusCode = http.StatusBadGateway
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.setStateCookie(w, r, start.StateCookie)
+	h.respondJSON(w, map[string]string{"auth_url": start.AuthURL}, http.StatusOK)
+}
+
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	// The provider reports user-facing failures (e.g. consent denied) here
+	if errCode := query.Get("error"); errCode != "" {
+		h.respondError(w, "Identity provider returned: "+errCode, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		h.respondError(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	// State is single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	authResponse, err := h.oidcService.CompleteLogin(r.Context(), vars["provider"], query.Get("code"), query.Get("state"), cookie.Value)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "unknown identity provider":
+			statusCode = http.StatusNotFound
+		case "invalid login state":
+			statusCode = http.StatusBadRequest
+		case "code exchange failed", "missing id_token", "invalid id_token", "identity provider did not supply an email":
+			statusCode = http.StatusUnauthorized
+		case "email already registered", "identity already linked":
+			statusCode = http.StatusConflict
+		case "user quota exceeded", "account disabled":
+			statusCode = http.StatusForbidden
+		case "identity provider unavailable":
+			statusCode = http.StatusBadGateway
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, authResponse, http.StatusOK)
+}
+
+func (h *OIDCHandler) setStateCookie(w http.ResponseWriter, r *http.Request, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *OIDCHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *OIDCHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+type PrivacyHandler struct {
+	privacyService *services.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *services.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+	}
+}
+
+func (h *PrivacyHandler) RequestExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := h.privacyService.RequestExport(r.Context(), userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "export already in progress" {
+			statusCode = http.StatusConflict
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, export, http.StatusAccepted)
+}
+
+func (h *PrivacyHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	exportID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.privacyService.GetExport(r.Context(), exportID, userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "export not found" {
+			statusCode = http.StatusNotFound
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, export, http.StatusOK)
+}
+
+// Download serves an export through its signed link; the signature stands
+// in for authentication so the link also works from a browser.
+func (h *PrivacyHandler) Download(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	exportID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	path, err := h.privacyService.OpenDownload(r.Context(), exportID, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "download link expired", "invalid download link":
+			statusCode = http.StatusForbidden
+		case "export not found":
+			statusCode = http.StatusNotFound
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		h.respondError(w, "export not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="data-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "data-export.zip", info.ModTime(), file)
+}
+
+func (h *PrivacyHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *PrivacyHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -29,6 +29,12 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
 	var input services.CreateTaskInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		h.respondError(w, "Invalid request body", http.StatusBadRequest)
@@ -41,9 +47,9 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := h.taskService.CreateTask(userID, input)
+	task, err := h.taskService.CreateTask(r.Context(), workspaceID, userID, input)
 	if err != nil {
-		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		h.respondError(w, err.Error(), taskErrorStatus(err))
 		return
 	}
 
@@ -57,6 +63,12 @@ func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
 	vars := mux.Vars(r)
 	taskID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -64,13 +76,9 @@ func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := h.taskService.GetTaskByID(taskID, userID)
+	task, err := h.taskService.GetTaskByID(r.Context(), workspaceID, taskID, userID)
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "task not found or unauthorized" {
-			statusCode = http.StatusNotFound
-		}
-		h.respondError(w, err.Error(), statusCode)
+		h.respondError(w, err.Error(), taskErrorStatus(err))
 		return
 	}
 
@@ -84,16 +92,20 @@ func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get status filter from query parameters
-	status := r.URL.Query().Get("status")
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
 
-	tasks, err := h.taskService.GetUserTasks(userID, status)
+	// Get status and assignee filters from query parameters
+	query := r.URL.Query()
+	tasks, err := h.taskService.GetUserTasks(r.Context(), workspaceID, userID, services.TaskListInput{
+		Status:   query.Get("status"),
+		Assignee: query.Get("assignee"),
+	})
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "invalid status filter" {
-			statusCode = http.StatusBadRequest
-		}
-		h.respondError(w, err.Error(), statusCode)
+		h.respondError(w, err.Error(), taskErrorStatus(err))
 		return
 	}
 
@@ -107,6 +119,12 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
 	vars := mux.Vars(r)
 	taskID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -126,13 +144,9 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := h.taskService.UpdateTask(taskID, userID, input)
+	task, err := h.taskService.UpdateTask(r.Context(), workspaceID, taskID, userID, input)
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "task not found or unauthorized" {
-			statusCode = http.StatusNotFound
-		}
-		h.respondError(w, err.Error(), statusCode)
+		h.respondError(w, err.Error(), taskErrorStatus(err))
 		return
 	}
 
@@ -146,6 +160,12 @@ func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
 	vars := mux.Vars(r)
 	taskID, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -153,13 +173,9 @@ func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.taskService.DeleteTask(taskID, userID)
+	err = h.taskService.DeleteTask(r.Context(), workspaceID, taskID, userID)
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if err.Error() == "task not found or unauthorized" {
-			statusCode = http.StatusNotFound
-		}
-		h.respondError(w, err.Error(), statusCode)
+		h.respondError(w, err.Error(), taskErrorStatus(err))
 		return
 	}
 
@@ -175,15 +191,125 @@ func (h *TaskHandler) GetStatistics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats, err := h.taskService.GetUserStatistics(userID)
+	workspaceID, err := workspaceIDParam(r)
 	if err != nil {
-		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	groupByAssignee := r.URL.Query().Get("group_by") == "assignee"
+	stats, err := h.taskService.GetUserStatistics(r.Context(), workspaceID, userID, groupByAssignee)
+	if err != nil {
+		h.respondError(w, err.Error(), taskErrorStatus(err))
 		return
 	}
 
 	h.respondJSON(w, stats, http.StatusOK)
 }
 
+func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.AssignTaskInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.taskService.AssignTask(r.Context(), workspaceID, taskID, userID, input)
+	if err != nil {
+		h.respondError(w, err.Error(), taskErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, task, http.StatusOK)
+}
+
+func (h *TaskHandler) GetAssignments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	assignments, err := h.taskService.GetAssignmentHistory(r.Context(), workspaceID, taskID, userID)
+	if err != nil {
+		h.respondError(w, err.Error(), taskErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, assignments, http.StatusOK)
+}
+
+// GetAssignedTasks lists the caller's assigned tasks from every workspace.
+func (h *TaskHandler) GetAssignedTasks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tasks, err := h.taskService.GetAssignedTasks(r.Context(), userID)
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, tasks, http.StatusOK)
+}
+
+// workspaceIDParam reads the workspace from the route. The legacy /tasks
+// routes have none, which yields 0: the user's personal workspace.
+func workspaceIDParam(r *http.Request) (int, error) {
+	param, ok := mux.Vars(r)["workspaceID"]
+	if !ok {
+		return 0, nil
+	}
+	return strconv.Atoi(param)
+}
+
+func taskErrorStatus(err error) int {
+	switch err.Error() {
+	case "task not found or unauthorized", "workspace not found":
+		return http.StatusNotFound
+	case "insufficient workspace role", "task quota exceeded":
+		return http.StatusForbidden
+	case "invalid status filter", "invalid assignee filter", "assignee must be a member of the workspace":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func (h *TaskHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+type TokenHandler struct {
+	tokenService *services.TokenService
+	validator    *validator.Validate
+}
+
+func NewTokenHandler(tokenService *services.TokenService) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+		validator:    validator.New(),
+	}
+}
+
+func (h *TokenHandler) GetTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.tokenService.GetUserTokens(r.Context(), userID)
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, tokens, http.StatusOK)
+}
+
+func (h *TokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.CreateTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, h.formatValidationError(err), http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.tokenService.CreateToken(r.Context(), userID, input)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "expiry must be in the future" {
+			statusCode = http.StatusBadRequest
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, token, http.StatusCreated)
+}
+
+func (h *TokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	tokenID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.tokenService.RevokeToken(r.Context(), tokenID, userID)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "token not found or unauthorized" {
+			statusCode = http.StatusNotFound
+		}
+		h.respondError(w, err.Error(), statusCode)
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Token revoked successfully",
+	}, http.StatusOK)
+}
+
+func (h *TokenHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *TokenHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
+
+func (h *TokenHandler) formatValidationError(err error) string {
+	if validationErrors, ok := err.(validator.ValidationErrors); ok {
+		for _, e := range validationErrors {
+			switch e.Tag() {
+			case "required":
+				return e.Field() + " is required"
+			case "min":
+				return e.Field() + " is too short"
+			case "max":
+				return e.Field() + " is too long"
+			case "oneof":
+				return "Scopes must be one of: tasks:read, tasks:write"
+			}
+		}
+	}
+	return "Validation error"
+}
@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/malex1718/go-api-demo/internal/services"
+)
+
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+	validator        *validator.Validate
+}
+
+func NewWorkspaceHandler(workspaceService *services.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		validator:        validator.New(),
+	}
+}
+
+func (h *WorkspaceHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaces, err := h.workspaceService.ListWorkspaces(r.Context(), userID)
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, workspaces, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input services.WorkspaceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, "Name must be between 1 and 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(r.Context(), userID, input)
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, workspace, http.StatusCreated)
+}
+
+func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.GetWorkspace(r.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, workspace, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.WorkspaceInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, "Name must be between 1 and 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.RenameWorkspace(r.Context(), workspaceID, userID, input)
+	if err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, workspace, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.workspaceService.DeleteWorkspace(r.Context(), workspaceID, userID); err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Workspace deleted successfully",
+	}, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.workspaceService.GetMembers(r.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, members, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	workspaceID, err := strconv.Atoi(vars["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	memberID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		h.respondError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.UpdateMemberInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, "Role must be one of: owner, admin, member, viewer", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.workspaceService.UpdateMemberRole(r.Context(), workspaceID, userID, memberID, input); err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Member updated successfully",
+	}, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	workspaceID, err := strconv.Atoi(vars["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	memberID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		h.respondError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(r.Context(), workspaceID, userID, memberID); err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Member removed successfully",
+	}, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.InviteInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if err := h.validator.Struct(input); err != nil {
+		h.respondError(w, "A valid email and a role of admin, member or viewer are required", http.StatusBadRequest)
+		return
+	}
+
+	invitation, err := h.workspaceService.Invite(r.Context(), workspaceID, userID, input)
+	if err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, invitation, http.StatusCreated)
+}
+
+func (h *WorkspaceHandler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	invitations, err := h.workspaceService.GetInvitations(r.Context(), workspaceID, userID)
+	if err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, invitations, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	workspaceID, err := strconv.Atoi(vars["workspaceID"])
+	if err != nil {
+		h.respondError(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	invitationID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.workspaceService.RevokeInvitation(r.Context(), workspaceID, userID, invitationID); err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, map[string]string{
+		"message": "Invitation revoked successfully",
+	}, http.StatusOK)
+}
+
+// GetMyInvitations lists the pending invitations for the signed-in user.
+func (h *WorkspaceHandler) GetMyInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invitations, err := h.workspaceService.GetMyInvitations(r.Context(), userID)
+	if err != nil {
+		h.respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondJSON(w, invitations, http.StatusOK)
+}
+
+func (h *WorkspaceHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value("userID").(int)
+	if !ok {
+		h.respondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invitationID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, "Invalid invitation ID", http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.workspaceService.AcceptInvitation(r.Context(), invitationID, userID)
+	if err != nil {
+		h.respondError(w, err.Error(), workspaceErrorStatus(err))
+		return
+	}
+
+	h.respondJSON(w, workspace, http.StatusOK)
+}
+
+func workspaceErrorStatus(err error) int {
+	switch err.Error() {
+	case "workspace not found", "membership not found", "invitation not found":
+		return http.StatusNotFound
+	case "insufficient workspace role":
+		return http.StatusForbidden
+	case "invitation expired":
+		return http.StatusGone
+	case "workspace must keep an owner", "user is already a member":
+		return http.StatusConflict
+	case "personal workspace cannot be shared", "personal workspace cannot be deleted":
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *WorkspaceHandler) respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *WorkspaceHandler) respondError(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -0,0 +1,142 @@
+// Package health runs the dependency checks behind the readiness endpoint.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a report and of each check.
+const (
+	StatusOK           = "ok"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// CheckFunc reports whether a dependency is usable. It should give up
+// when ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// DurationMS is how long the check took, in milliseconds
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Report is the outcome of all checks; Status is ok only if every check
+// passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Checks holds the registered checks and whether the process is shutting
+// down.
+type Checks struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]CheckFunc
+
+	draining atomic.Bool
+}
+
+// New returns an empty set of checks, each of which gets timeout to
+// complete.
+func New(timeout time.Duration) *Checks {
+	return &Checks{
+		timeout: timeout,
+		checks:  make(map[string]CheckFunc),
+	}
+}
+
+// Register adds a check, replacing any other with the same name.
+func (c *Checks) Register(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Drain makes every later report fail without running the checks, so load
+// balancers stop routing to the process while it shuts down.
+func (c *Checks) Drain() {
+	c.draining.Store(true)
+}
+
+// Run runs the checks concurrently.
+func (c *Checks) Run(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusShuttingDown}
+	}
+
+	c.mu.RLock()
+	checks := make(map[string]CheckFunc, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checks) run(ctx context.Context, check CheckFunc) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Ping checks that db accepts connections.
+func Ping(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// SchemaVersion checks that the migrations reported by current reach
+// expected. A newer schema passes, so the previous release keeps serving
+// while a deploy migrates ahead of it.
+func SchemaVersion(current func(ctx context.Context) (int, error), expected int) CheckFunc {
+	return func(ctx context.Context) error {
+		version, err := current(ctx)
+		if err != nil {
+			return err
+		}
+		if version < expected {
+			return fmt.Errorf("schema at version %d, expected %d", version, expected)
+		}
+		return nil
+	}
+}
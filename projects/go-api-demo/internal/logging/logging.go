@@ -0,0 +1,63 @@
+// Package logging builds the application's log/slog logger and carries
+// per-request loggers through a context.Context.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+type contextKey struct{}
+
+// New returns a logger writing JSON, or human-readable text when format is
+// "text", at the level held by level. Sensitive attributes are redacted
+// whatever their group.
+func New(w io.Writer, format string, level *slog.LevelVar) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// WithContext returns a copy of ctx carrying logger.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// IsSensitive reports whether a header, field or attribute name holds a
+// credential.
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie", "code", "code_verifier":
+		return true
+	}
+	return strings.HasSuffix(key, "password") || strings.HasSuffix(key, "token") || strings.HasSuffix(key, "secret")
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
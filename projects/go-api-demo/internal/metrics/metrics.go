@@ -0,0 +1,98 @@
+// Package metrics holds the Prometheus collectors of the API, served at
+// /metrics in the text exposition format.
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Registry holds every collector of the process, plus the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_rate_limited_total",
+		Help: "Requests rejected by the rate limiter, by tenant.",
+	}, []string{"tenant"})
+
+	Logins = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by outcome: success, failure or lockout.",
+	}, []string{"outcome"})
+
+	TasksCreated = factory.NewCounter(prometheus.CounterOpts{
+		Name: "tasks_created_total",
+		Help: "Tasks created.",
+	})
+
+	TasksCompleted = factory.NewCounter(prometheus.CounterOpts{
+		Name: "tasks_completed_total",
+		Help: "Tasks moved to the completed status.",
+	})
+)
+
+// Login outcomes
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginLockout = "lockout"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// dbCollectors are the pool collectors by name, for UnregisterDB.
+var (
+	dbCollectorsMu sync.Mutex
+	dbCollectors   = make(map[string]prometheus.Collector)
+)
+
+// RegisterDB exports the open, in-use, idle and wait statistics of a
+// connection pool under the go_sql_* metrics, labelled with name. Names
+// must be unique among registered pools.
+func RegisterDB(db *sql.DB, name string) error {
+	collector := collectors.NewDBStatsCollector(db, name)
+	if err := Registry.Register(collector); err != nil {
+		return err
+	}
+
+	dbCollectorsMu.Lock()
+	defer dbCollectorsMu.Unlock()
+	dbCollectors[name] = collector
+	return nil
+}
+
+// UnregisterDB stops exporting the pool registered as name, so a pool that
+// replaces it can take the name.
+func UnregisterDB(name string) {
+	dbCollectorsMu.Lock()
+	defer dbCollectorsMu.Unlock()
+
+	if collector, ok := dbCollectors[name]; ok {
+		Registry.Unregister(collector)
+		delete(dbCollectors, name)
+	}
+}
@@ -1,14 +1,51 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/signing"
 )
 
-func AuthMiddleware(secret string) fiber.Handler {
+// TokenAuthenticator resolves opaque (non-JWT) bearer tokens such as
+// personal access tokens. issuedAt is when the token was created.
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (userID int, scopes []string, issuedAt time.Time, err error)
+}
+
+// Authenticators tries each authenticator in turn, so personal access
+// tokens and OAuth access tokens can share one middleware.
+type Authenticators []TokenAuthenticator
+
+func (a Authenticators) AuthenticateToken(ctx context.Context, token string) (int, []string, time.Time, error) {
+	err := errors.New("invalid token")
+	for _, authenticator := range a {
+		var userID int
+		var scopes []string
+		var issuedAt time.Time
+		userID, scopes, issuedAt, err = authenticator.AuthenticateToken(ctx, token)
+		if err == nil {
+			return userID, scopes, issuedAt, nil
+		}
+	}
+	return 0, nil, time.Time{}, err
+}
+
+// SessionChecker is consulted on every request, after the token itself is
+// validated, so admin actions (disabling an account, forcing a logout)
+// apply immediately. issuedAt is the iat of a JWT or the creation time of
+// an opaque token.
+type SessionChecker interface {
+	CheckSession(ctx context.Context, userID int, issuedAt time.Time) (string, error)
+}
+
+func AuthMiddleware(keys *signing.Keys, tokens TokenAuthenticator, sessions SessionChecker) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -25,11 +62,24 @@ func AuthMiddleware(secret string) fiber.Handler {
 		}
 
 		tokenString := tokenParts[1]
+
+		// Anything that is not a JWT is an opaque token (PAT or OAuth)
+		if strings.Count(tokenString, ".") != 2 {
+			userID, scopes, issuedAt, err := tokens.AuthenticateToken(c.UserContext(), tokenString)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Invalid token",
+				})
+			}
+
+			return authenticated(c, sessions, userID, scopes, issuedAt)
+		}
+
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fiber.ErrUnauthorized
 			}
-			return []byte(secret), nil
+			return keys.JWT(), nil
 		})
 
 		if err != nil || !token.Valid {
@@ -45,14 +95,65 @@ func AuthMiddleware(secret string) fiber.Handler {
 			})
 		}
 
-		userID, err := uuid.Parse(claims["user_id"].(string))
-		if err != nil {
+		// MFA challenge tokens are not access tokens
+		if purpose, _ := claims["purpose"].(string); purpose != "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid token",
+			})
+		}
+
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "Invalid user ID in token",
 			})
 		}
 
-		c.Locals("userID", userID)
-		return c.Next()
+		issuedAt, _ := claims["iat"].(float64)
+		scope, _ := claims["scope"].(string)
+
+		// Impersonation (RFC 8693 "act" claim): the acting admin must still
+		// be an enabled admin with a valid session
+		if act, ok := claims["act"].(map[string]interface{}); ok {
+			sub, _ := act["sub"].(string)
+			actorID, err := strconv.Atoi(sub)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Invalid token",
+				})
+			}
+
+			role, err := sessions.CheckSession(c.UserContext(), actorID, time.Unix(int64(issuedAt), 0))
+			if err != nil || role != models.RoleAdmin {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Invalid token",
+				})
+			}
+
+			c.Locals("actorID", actorID)
+		}
+
+		return authenticated(c, sessions, int(userID), strings.Fields(scope), time.Unix(int64(issuedAt), 0))
 	}
+}
+
+func authenticated(c *fiber.Ctx, sessions SessionChecker, userID int, scopes []string, issuedAt time.Time) error {
+	role, err := sessions.CheckSession(c.UserContext(), userID, issuedAt)
+	if err != nil {
+		message := "Invalid token"
+		switch err.Error() {
+		case "account disabled":
+			message = "Account disabled"
+		case "session revoked":
+			message = "Session revoked"
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	c.Locals("userID", userID)
+	c.Locals("scopes", scopes)
+	c.Locals("role", role)
+	return c.Next()
 }
\ No newline at end of file
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/malex1718/go-api-demo/internal/config"
+)
+
+// Deadline gives each request's context the timeout of the first route
+// matching it, or fallback, so the queries it runs are cancelled once it
+// expires. Zero means no deadline. In an app served from another, as in
+// multi-tenant mode, register it after NestedApp.
+func Deadline(fallback time.Duration, routes []config.RouteTimeout) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		timeout := fallback
+		for _, route := range routes {
+			if route.Matches(c.Method(), c.Path()) {
+				timeout = route.Timeout
+				break
+			}
+		}
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
\ No newline at end of file
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -11,9 +14,22 @@ func ErrorHandler(c *fiber.Ctx, err error) error {
 	if e, ok := err.(*fiber.Error); ok {
 		code = e.Code
 		message = e.Message
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		// The request ran past its Deadline
+		code = fiber.StatusServiceUnavailable
+		message = "Request timed out"
+		RequestLog(c).Warn("request timed out", "error", err)
+	} else {
+		RequestLog(c).Error("unhandled error", "error", err)
+	}
+
+	body := fiber.Map{
+		"error":      message,
+		"request_id": CurrentRequestID(c),
+	}
+	if traceID := CurrentTraceID(c); traceID != "" {
+		body["trace_id"] = traceID
 	}
 
-	return c.Status(code).JSON(fiber.Map{
-		"error": message,
-	})
+	return c.Status(code).JSON(body)
 }
\ No newline at end of file
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ImpersonationHeader is set on every response to an impersonation token,
+// carrying the acting admin's id, so the UI can show a banner.
+const ImpersonationHeader = "X-Impersonated-By"
+
+type ImpersonationRecorder interface {
+	RecordImpersonatedRequest(ctx context.Context, actorID, userID int, method, path, ip string, status int)
+}
+
+// ImpersonationAudit wraps the whole chain: after the route has run, and
+// AuthMiddleware has stored the acting admin, it tags the response and
+// writes an audit entry with both identities. Register it before the
+// routes.
+func ImpersonationAudit(recorder ImpersonationRecorder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		actorID, ok := c.Locals("actorID").(int)
+		if !ok {
+			return err
+		}
+		userID, _ := c.Locals("userID").(int)
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		c.Set(ImpersonationHeader, strconv.Itoa(actorID))
+		// Requests that ran out of time are recorded all the same
+		ctx := context.WithoutCancel(c.UserContext())
+		recorder.RecordImpersonatedRequest(ctx, actorID, userID, c.Method(), c.Path(), c.IP(), status)
+		return err
+	}
+}
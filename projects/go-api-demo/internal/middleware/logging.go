@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/malex1718/go-api-demo/internal/logging"
+	"github.com/malex1718/go-api-demo/internal/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestLogger gives every request a child of base carrying its request
+// id, trace id, method and path, available through RequestLog and the user
+// context, and logs one line per request with the route, status, user and
+// latency. Register it right after RequestID and Tracing.
+func RequestLogger(base *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		logger := base.With(
+			slog.String("request_id", CurrentRequestID(c)),
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+		)
+		if spanContext := trace.SpanContextFromContext(c.UserContext()); spanContext.IsValid() {
+			logger = logger.With(
+				slog.String("trace_id", spanContext.TraceID().String()),
+				slog.String("span_id", spanContext.SpanID().String()),
+			)
+		}
+		c.Locals("logger", logger)
+		c.SetUserContext(logging.WithContext(c.UserContext(), logger))
+
+		if logger.Enabled(c.UserContext(), slog.LevelDebug) {
+			headers := make([]any, 0, 16)
+			c.Request().Header.VisitAll(func(key, value []byte) {
+				headers = append(headers, slog.String(string(key), string(value)))
+			})
+			logger.Debug("request started", slog.Group("headers", headers...))
+		}
+
+		err := c.Next()
+		status := responseStatus(c, err)
+
+		attrs := []slog.Attr{
+			slog.String("route", routeTemplate(c)),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.IP()),
+		}
+		if userID, ok := c.Locals("userID").(int); ok {
+			attrs = append(attrs, slog.Int("user_id", userID))
+		}
+		if tenant := CurrentTenant(c); tenant != nil {
+			attrs = append(attrs, slog.String("tenant", tenant.Slug))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.UserContext(), level, "request completed", attrs...)
+		return err
+	}
+}
+
+// NestedApp connects an app that an outer app dispatches to, as in
+// multi-tenant mode: it adopts the outer request id, span and logger and
+// records the matched route template, which the outer RequestLogger and
+// Tracing cannot see.
+func NestedApp() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := requestid.WithContext(c.UserContext(), CurrentRequestID(c))
+		if span, ok := c.Locals("span").(trace.Span); ok {
+			ctx = trace.ContextWithSpan(ctx, span)
+		}
+		c.SetUserContext(logging.WithContext(ctx, RequestLog(c)))
+
+		err := c.Next()
+		c.Locals("route", c.Route().Path)
+		return err
+	}
+}
+
+// RequestLog returns the request's logger, or the default logger outside
+// RequestLogger.
+func RequestLog(c *fiber.Ctx) *slog.Logger {
+	if logger, ok := c.Locals("logger").(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// responseStatus is the status the client gets once ErrorHandler has
+// turned err into a response.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// routeTemplate prefers the route exposed by a nested app, then the route
+// matched here, so log lines group by /tasks/:id rather than raw paths.
+func routeTemplate(c *fiber.Ctx) string {
+	if route, ok := c.Locals("route").(string); ok {
+		return route
+	}
+	return c.Route().Path
+}
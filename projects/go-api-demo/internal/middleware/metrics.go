@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/malex1718/go-api-demo/internal/metrics"
+)
+
+// Metrics counts and times requests by route template rather than raw
+// path, so /tasks/1 and /tasks/2 share a series. Register it before the
+// routes.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		status := responseStatus(c, err)
+
+		// Unmatched paths would otherwise each get their own series
+		route := routeTemplate(c)
+		if status == fiber.StatusNotFound && route == "/" {
+			route = "unmatched"
+		}
+
+		labels := []string{c.Method(), route, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
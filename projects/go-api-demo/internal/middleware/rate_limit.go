@@ -1,24 +1,83 @@
 package middleware
 
 import (
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/malex1718/go-api-demo/internal/metrics"
 )
 
-func RateLimiter() fiber.Handler {
+// RateLimiter allows max requests per minute and client IP.
+func RateLimiter(max int) fiber.Handler {
 	return limiter.New(limiter.Config{
-		Max:               100,
+		Max:               max,
 		Expiration:        1 * time.Minute,
 		LimiterMiddleware: limiter.SlidingWindow{},
 		KeyGenerator: func(c *fiber.Ctx) string {
 			return c.IP()
 		},
 		LimitReached: func(c *fiber.Ctx) error {
+			tenant := ""
+			if t := CurrentTenant(c); t != nil {
+				tenant = t.Slug
+			}
+			metrics.RateLimited.WithLabelValues(tenant).Inc()
+
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"error": "Too many requests",
 			})
 		},
 	})
-}
\ No newline at end of file
+}
+
+// TenantRateLimiter applies each tenant's own limit, or defaultMax for
+// tenants without one, so a busy tenant cannot use up another's budget.
+// Handler must run after TenantResolver.
+type TenantRateLimiter struct {
+	defaultMax int
+
+	mu       sync.Mutex
+	limiters map[string]tenantLimiter
+}
+
+type tenantLimiter struct {
+	max     int
+	handler fiber.Handler
+}
+
+func NewTenantRateLimiter(defaultMax int) *TenantRateLimiter {
+	return &TenantRateLimiter{
+		defaultMax: defaultMax,
+		limiters:   make(map[string]tenantLimiter),
+	}
+}
+
+func (l *TenantRateLimiter) Handler(c *fiber.Ctx) error {
+	tenant := CurrentTenant(c)
+	max := tenant.RateLimit
+	if max <= 0 {
+		max = l.defaultMax
+	}
+
+	l.mu.Lock()
+	entry, ok := l.limiters[tenant.Slug]
+	// A changed limit replaces the tenant's limiter
+	if !ok || entry.max != max {
+		entry = tenantLimiter{max: max, handler: RateLimiter(max)}
+		l.limiters[tenant.Slug] = entry
+	}
+	l.mu.Unlock()
+
+	return entry.handler(c)
+}
+
+// Forget drops the tenant's limiter, once it has been idle for longer than
+// the limiter's window and its counters are empty anyway.
+func (l *TenantRateLimiter) Forget(slug string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.limiters, slug)
+}
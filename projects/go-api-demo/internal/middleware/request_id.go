@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/malex1718/go-api-demo/internal/requestid"
+)
+
+// RequestID reuses the caller's X-Request-ID when it is well formed, or
+// generates one, echoes it in the response and stores it in the user
+// context, from which services and repositories pick it up. Register it
+// first.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		c.Set(requestid.Header, id)
+		c.Locals("requestID", id)
+		c.SetUserContext(requestid.WithContext(c.UserContext(), id))
+
+		return c.Next()
+	}
+}
+
+// CurrentRequestID returns the id stored by RequestID.
+func CurrentRequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals("requestID").(string)
+	return id
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole rejects callers whose account does not have one of the listed
+// roles. It must run after AuthMiddleware, which loads the role.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+
+		for _, allowed := range roles {
+			if role == allowed {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Insufficient permissions",
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireScopes rejects callers whose token does not carry every listed
+// scope. It must run after AuthMiddleware, which stores the scopes.
+func RequireScopes(required ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, _ := c.Locals("scopes").([]string)
+
+		have := make(map[string]bool, len(granted))
+		for _, scope := range granted {
+			have[scope] = true
+		}
+
+		for _, scope := range required {
+			if !have[scope] {
+				// RFC 6750 section 3.1
+				c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(
+					`Bearer error="insufficient_scope", scope="%s"`,
+					strings.Join(required, " "),
+				))
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "Insufficient scope",
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type TenantLookup interface {
+	Resolve(ctx context.Context, slug string) (*models.Tenant, error)
+}
+
+type TenantResolverConfig struct {
+	// BaseDomain makes acme.<BaseDomain> resolve to tenant acme
+	BaseDomain string
+	// Header names the request header carrying the tenant slug
+	Header string
+	Lookup TenantLookup
+}
+
+// TenantResolver identifies the tenant of a request from, in order, the
+// subdomain, the tenant header, or the tenant claim of a session token, and
+// stores it for CurrentTenant. The token is not verified here: tenants
+// sign with their own key, so a token claiming another tenant fails
+// authentication later on.
+func TenantResolver(cfg TenantResolverConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		slug := tenantFromHost(c.Hostname(), cfg.BaseDomain)
+		if slug == "" && cfg.Header != "" {
+			slug = strings.ToLower(c.Get(cfg.Header))
+		}
+		if slug == "" {
+			slug = tenantFromToken(c.Get("Authorization"))
+		}
+
+		if slug == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Missing tenant",
+			})
+		}
+
+		tenant, err := cfg.Lookup.Resolve(c.UserContext(), slug)
+		if err != nil {
+			if err.Error() == "tenant not found" {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Tenant not found",
+				})
+			}
+			return err
+		}
+
+		if tenant.Status != models.TenantStatusActive {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Tenant suspended",
+			})
+		}
+
+		c.Locals("tenant", tenant)
+		return c.Next()
+	}
+}
+
+// CurrentTenant returns the tenant stored by TenantResolver.
+func CurrentTenant(c *fiber.Ctx) *models.Tenant {
+	tenant, _ := c.Locals("tenant").(*models.Tenant)
+	return tenant
+}
+
+func tenantFromHost(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+
+	host = strings.ToLower(host)
+	if i := strings.LastIndexByte(host, ':'); i >= 0 {
+		host = host[:i]
+	}
+
+	sub := strings.TrimSuffix(host, "."+strings.ToLower(baseDomain))
+	if sub == host || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+func tenantFromToken(authHeader string) string {
+	tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || strings.Count(tokenString, ".") != 2 {
+		return ""
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return ""
+	}
+
+	slug, _ := claims[models.TenantClaim].(string)
+	return slug
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span for every request, continuing the trace of
+// an incoming W3C traceparent header, and stores it in the user context so
+// service and SQL spans nest under it. Register it right after RequestID.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaders{c})
+		ctx, span := tracing.Tracer().Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+				semconv.ClientAddress(c.IP()),
+				semconv.UserAgentOriginal(c.Get(fiber.HeaderUserAgent)),
+			),
+		)
+		defer span.End()
+
+		c.Locals("span", span)
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		status := responseStatus(c, err)
+
+		route := routeTemplate(c)
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetStatus(codes.Error, "")
+		}
+
+		return err
+	}
+}
+
+// CurrentTraceID returns the id of the trace the request belongs to, or "".
+func CurrentTraceID(c *fiber.Ctx) string {
+	span, ok := c.Locals("span").(trace.Span)
+	if !ok || !span.SpanContext().HasTraceID() {
+		return ""
+	}
+	return span.SpanContext().TraceID().String()
+}
+
+// requestHeaders reads propagation headers off the Fiber request.
+type requestHeaders struct {
+	c *fiber.Ctx
+}
+
+func (h requestHeaders) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h requestHeaders) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h requestHeaders) Keys() []string {
+	keys := make([]string, 0, 16)
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
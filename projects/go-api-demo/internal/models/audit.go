@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	AuditActionLoginLockout       = "login.lockout"
+	AuditActionAdminUserUpdated   = "admin.user_updated"
+	AuditActionAdminPasswordReset = "admin.password_reset"
+	AuditActionAdminLogout        = "admin.logout"
+	AuditActionAdminImpersonate   = "admin.impersonate"
+	// AuditActionImpersonatedRequest is written for every request made
+	// with an impersonation token
+	AuditActionImpersonatedRequest = "admin.impersonated_request"
+)
+
+type AuditEvent struct {
+	ID        int               `json:"id" db:"id"`
+	UserID    *int              `json:"user_id,omitempty" db:"user_id"`
+	Action    string            `json:"action" db:"action"`
+	IPAddress string            `json:"ip_address,omitempty" db:"ip_address"`
+	Metadata  map[string]string `json:"metadata" db:"metadata"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+type DataExport struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Status      string     `json:"status" db:"status"`
+	FileName    string     `json:"-" db:"file_name"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
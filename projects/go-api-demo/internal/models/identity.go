@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+type UserIdentity struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type OAuthClient struct {
+	ID               int       `json:"id" db:"id"`
+	ClientID         string    `json:"client_id" db:"client_id"`
+	ClientSecretHash string    `json:"-" db:"client_secret_hash"`
+	OwnerID          int       `json:"owner_id" db:"owner_id"`
+	Name             string    `json:"name" db:"name"`
+	RedirectURIs     []string  `json:"redirect_uris" db:"redirect_uris"`
+	Scopes           []string  `json:"scopes" db:"scopes"`
+	Confidential     bool      `json:"confidential" db:"confidential"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+type OAuthAuthorizationCode struct {
+	CodeHash      string    `json:"-" db:"code_hash"`
+	ClientID      string    `json:"client_id" db:"client_id"`
+	UserID        int       `json:"user_id" db:"user_id"`
+	RedirectURI   string    `json:"redirect_uri" db:"redirect_uri"`
+	Scopes        []string  `json:"scopes" db:"scopes"`
+	CodeChallenge string    `json:"-" db:"code_challenge"`
+	ExpiresAt     time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+type OAuthAccessToken struct {
+	ID        int        `json:"id" db:"id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ClientID  string     `json:"client_id" db:"client_id"`
+	UserID    *int       `json:"user_id,omitempty" db:"user_id"`
+	Scopes    []string   `json:"scopes" db:"scopes"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
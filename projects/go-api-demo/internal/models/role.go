@@ -0,0 +1,6 @@
+package models
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
@@ -0,0 +1,22 @@
+package models
+
+const (
+	ScopeTasksRead     = "tasks:read"
+	ScopeTasksWrite    = "tasks:write"
+	ScopeAccountManage = "account:manage"
+)
+
+// DefaultScopes is the scope set granted to interactive sessions.
+// Personal access tokens may only carry the tasks:* subset.
+var DefaultScopes = []string{
+	ScopeTasksRead,
+	ScopeTasksWrite,
+	ScopeAccountManage,
+}
+
+// ScopeDescriptions are shown on the OAuth consent screen. Only these
+// scopes can be granted to third-party clients.
+var ScopeDescriptions = map[string]string{
+	ScopeTasksRead:  "Read your tasks",
+	ScopeTasksWrite: "Create, edit and delete your tasks",
+}
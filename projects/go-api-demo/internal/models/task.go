@@ -16,7 +16,10 @@ const (
 
 type Task struct {
 	ID          uuid.UUID  `json:"id" db:"id"`
-	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	UserID      *uuid.UUID `json:"user_id" db:"user_id"`
+	WorkspaceID int        `json:"workspace_id" db:"workspace_id"`
+	AssigneeID  *int       `json:"assignee_id" db:"assignee_id"`
+	AssignedAt  *time.Time `json:"assigned_at,omitempty" db:"assigned_at"`
 	Title       string     `json:"title" db:"title"`
 	Description string     `json:"description" db:"description"`
 	Status      TaskStatus `json:"status" db:"status"`
@@ -43,4 +46,19 @@ type TaskFilter struct {
 	Status TaskStatus `query:"status"`
 	Limit  int        `query:"limit"`
 	Offset int        `query:"offset"`
+
+	// Resolved by the service from the assignee=me|none|<id> parameter
+	AssigneeID *int `query:"-"`
+	Unassigned bool `query:"-"`
+	CreatedBy  *int `query:"-"`
+}
+
+// TaskAssignment records one (re)assignment of a task. A nil AssigneeID
+// means the task was unassigned.
+type TaskAssignment struct {
+	ID         int       `json:"id" db:"id"`
+	TaskID     int       `json:"task_id" db:"task_id"`
+	AssigneeID *int      `json:"assignee_id" db:"assignee_id"`
+	AssignedBy *int      `json:"assigned_by,omitempty" db:"assigned_by"`
+	AssignedAt time.Time `json:"assigned_at" db:"assigned_at"`
 }
\ No newline at end of file
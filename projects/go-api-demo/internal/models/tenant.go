@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	TenantStatusActive    = "active"
+	TenantStatusSuspended = "suspended"
+
+	// DefaultTenantSlug owns the data of single-tenant deployments
+	DefaultTenantSlug = "default"
+
+	// TenantClaim carries the tenant slug in session tokens
+	TenantClaim = "tid"
+)
+
+type Tenant struct {
+	ID     int    `json:"id" db:"id"`
+	Slug   string `json:"slug" db:"slug"`
+	Name   string `json:"name" db:"name"`
+	Status string `json:"status" db:"status"`
+	// SchemaName is the tenant's schema in schema-per-tenant mode
+	SchemaName string `json:"schema_name,omitempty" db:"schema_name"`
+	// Quotas; nil means unlimited
+	MaxUsers *int `json:"max_users,omitempty" db:"max_users"`
+	MaxTasks *int `json:"max_tasks,omitempty" db:"max_tasks"`
+	// RateLimit is requests per minute and IP; 0 uses the default
+	RateLimit int       `json:"rate_limit" db:"rate_limit"`
+	UserCount int       `json:"user_count" db:"user_count"`
+	TaskCount int       `json:"task_count" db:"task_count"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
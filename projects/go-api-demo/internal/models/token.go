@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+type PersonalAccessToken struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"token_prefix"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
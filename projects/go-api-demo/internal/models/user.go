@@ -11,8 +11,24 @@ type User struct {
 	Email     string    `json:"email" db:"email"`
 	Password  string    `json:"-" db:"password"`
 	Name      string    `json:"name" db:"name"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	AvatarURL string    `json:"avatar_url,omitempty" db:"avatar_url"`
+	Role      string    `json:"role" db:"role"`
+	Disabled  bool      `json:"disabled" db:"disabled"`
+	// PasswordResetRequired limits the next sessions to changing the password
+	PasswordResetRequired bool       `json:"password_reset_required" db:"password_reset_required"`
+	SessionsRevokedAt     *time.Time `json:"-" db:"sessions_revoked_at"`
+	// DeletionScheduledAt is set while the account waits for its hard delete
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type RecoveryCode struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 }
 
 type RegisterRequest struct {
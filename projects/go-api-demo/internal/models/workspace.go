@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+	WorkspaceRoleViewer = "viewer"
+)
+
+// WorkspaceRoleRank orders the roles, so requiring one role also admits
+// every role above it.
+var WorkspaceRoleRank = map[string]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleMember: 2,
+	WorkspaceRoleAdmin:  3,
+	WorkspaceRoleOwner:  4,
+}
+
+type Workspace struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Personal  bool      `json:"personal" db:"personal"`
+	CreatedBy *int      `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WorkspaceMembership is a workspace as seen by one of its members.
+type WorkspaceMembership struct {
+	Workspace
+	Role string `json:"role" db:"role"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspace_id" db:"workspace_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	Username    string    `json:"username" db:"username"`
+	Email       string    `json:"email" db:"email"`
+	Role        string    `json:"role" db:"role"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type WorkspaceInvitation struct {
+	ID          int        `json:"id" db:"id"`
+	WorkspaceID int        `json:"workspace_id" db:"workspace_id"`
+	Email       string     `json:"email" db:"email"`
+	Role        string     `json:"role" db:"role"`
+	InvitedBy   *int       `json:"invited_by,omitempty" db:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
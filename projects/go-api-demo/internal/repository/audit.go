@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (user_id, action, ip_address, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		event.UserID,
+		event.Action,
+		event.IPAddress,
+		metadata,
+		now,
+	).Scan(&event.ID)
+
+	if err != nil {
+		return err
+	}
+
+	event.CreatedAt = now
+	return nil
+}
+
+func (r *AuditRepository) GetByUserID(ctx context.Context, userID int) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT id, user_id, action, ip_address, metadata, created_at
+		FROM audit_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event := &models.AuditEvent{}
+		var ipAddress sql.NullString
+		var metadata []byte
+		err := rows.Scan(
+			&event.ID,
+			&event.UserID,
+			&event.Action,
+			&ipAddress,
+			&metadata,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+		event.IPAddress = ipAddress.String
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/malex1718/go-api-demo/internal/requestid"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+// annotate appends a sqlcommenter comment (https://google.github.io/sqlcommenter/)
+// identifying the request and trace behind the statement, so Postgres
+// slow-query and pg_stat_activity entries can be traced back to the API log
+// line and span.
+func annotate(ctx context.Context, query string) string {
+	id := requestid.FromContext(ctx)
+	traceparent := tracing.Traceparent(ctx)
+	if id == "" && traceparent == "" {
+		return query
+	}
+
+	// Keys in alphabetical order, values URL-encoded and quoted
+	comment := "application='go-api-demo'"
+	if id != "" {
+		comment += ",request_id='" + commentValue(id) + "'"
+	}
+	if traceparent != "" {
+		comment += ",traceparent='" + commentValue(traceparent) + "'"
+	}
+	return query + " /*" + comment + "*/"
+}
+
+func commentValue(value string) string {
+	return strings.ReplaceAll(url.PathEscape(value), "'", `\'`)
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type ExportRepository struct {
+	db *sql.DB
+}
+
+func NewExportRepository(db *sql.DB) *ExportRepository {
+	return &ExportRepository{db: db}
+}
+
+func (r *ExportRepository) Create(ctx context.Context, export *models.DataExport) error {
+	query := `
+		INSERT INTO data_exports (user_id, status, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), export.UserID, models.ExportStatusPending, now).Scan(&export.ID)
+	if err != nil {
+		return err
+	}
+
+	export.Status = models.ExportStatusPending
+	export.CreatedAt = now
+	return nil
+}
+
+func (r *ExportRepository) GetByID(ctx context.Context, id int) (*models.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, file_name, error, expires_at, completed_at, created_at
+		FROM data_exports
+		WHERE id = $1`
+
+	export := &models.DataExport{}
+	var fileName, exportError sql.NullString
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), id).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&fileName,
+		&exportError,
+		&export.ExpiresAt,
+		&export.CompletedAt,
+		&export.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("export not found")
+	}
+
+	export.FileName = fileName.String
+	export.Error = exportError.String
+	return export, err
+}
+
+// HasPending reports whether the user already has an export being built.
+func (r *ExportRepository) HasPending(ctx context.Context, userID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM data_exports WHERE user_id = $1 AND status = $2)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), userID, models.ExportStatusPending).Scan(&exists)
+	return exists, err
+}
+
+func (r *ExportRepository) GetFilesByUserID(ctx context.Context, userID int) ([]string, error) {
+	query := `SELECT file_name FROM data_exports WHERE user_id = $1 AND file_name IS NOT NULL`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (r *ExportRepository) Complete(ctx context.Context, id int, fileName string, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = $1, file_name = $2, expires_at = $3, completed_at = $4
+		WHERE id = $5`
+
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), models.ExportStatusCompleted, fileName, expiresAt, time.Now(), id)
+	return err
+}
+
+func (r *ExportRepository) Fail(ctx context.Context, id int, message string) error {
+	query := `
+		UPDATE data_exports
+		SET status = $1, error = $2, completed_at = $3
+		WHERE id = $4`
+
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), models.ExportStatusFailed, message, time.Now(), id)
+	return err
+}
+
+// DeleteExpired removes exports past their expiry and returns the file
+// names that were attached to them.
+func (r *ExportRepository) DeleteExpired(ctx context.Context, before time.Time) ([]string, error) {
+	query := `
+		DELETE FROM data_exports
+		WHERE expires_at IS NOT NULL AND expires_at <= $1
+		RETURNING file_name`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var file sql.NullString
+		if err := rows.Scan(&file); err != nil {
+			return nil, err
+		}
+		if file.Valid {
+			files = append(files, file.String)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
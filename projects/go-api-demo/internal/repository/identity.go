@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) Create(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		now,
+	).Scan(&identity.ID)
+
+	if err != nil {
+		return err
+	}
+
+	identity.CreatedAt = now
+	return nil
+}
+
+func (r *IdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2`
+
+	identity := &models.UserIdentity{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("identity not found")
+	}
+
+	return identity, err
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// Acquire counts an attempt for key before it is made, unless key is
+// locked, backing off or already at maxFailures. The check and the increment are one statement, so concurrent
+// attempts cannot all pass the check before any of them is counted.
+// Failures older than windowStart no longer count and restart at one. A
+// refused attempt returns false and is not counted.
+func (r *LoginAttemptRepository) Acquire(ctx context.Context, key string, now, windowStart time.Time, maxFailures int, backoffBase, maxBackoff time.Duration) (bool, error) {
+	// The backoff after n failures is backoffBase * 2^(n-1), capped at
+	// maxBackoff
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (tenant_id, key) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failure_at < $3 THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = $2
+		WHERE (login_attempts.locked_until IS NULL OR login_attempts.locked_until <= $2)
+			AND (login_attempts.last_failure_at < $3
+				OR (login_attempts.failures < $4
+					AND (login_attempts.failures = 0
+						OR login_attempts.last_failure_at
+							+ LEAST($5 * power(2, LEAST(login_attempts.failures - 1, 30)), $6) * interval '1 millisecond' <= $2)))
+		RETURNING failures`
+
+	var failures int
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		key,
+		now,
+		windowStart,
+		maxFailures,
+		backoffBase.Milliseconds(),
+		maxBackoff.Milliseconds(),
+	).Scan(&failures)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Refund takes back an attempt counted by Acquire that did not fail.
+func (r *LoginAttemptRepository) Refund(ctx context.Context, key string) error {
+	query := `UPDATE login_attempts SET failures = GREATEST(failures - 1, 0) WHERE key = $1`
+
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), key)
+	return err
+}
+
+// Lock locks key until the given time once it has reached maxFailures,
+// and returns its failures and whether this call locked it. A key that is
+// already locked is left alone, so each lockout is reported once.
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, maxFailures int, now, until time.Time) (int, bool, error) {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $1
+		WHERE key = $2
+			AND failures >= $3
+			AND (locked_until IS NULL OR locked_until <= $4)
+		RETURNING failures`
+
+	var failures int
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), until, key, maxFailures, now).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return failures, true, nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1`
+
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), key)
+	return err
+}
+
+// DeleteExpired removes the counters whose last failure is older than
+// windowStart and whose lockout has ended by now, and returns how many
+// were removed. Such counters no longer affect any attempt.
+func (r *LoginAttemptRepository) DeleteExpired(ctx context.Context, windowStart, now time.Time) (int64, error) {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < $1
+			AND (locked_until IS NULL OR locked_until <= $2)`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), windowStart, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+func (r *MFARepository) GetTOTP(ctx context.Context, userID int) (string, bool, error) {
+	query := `SELECT totp_secret, totp_enabled FROM users WHERE id = $1`
+
+	var secret sql.NullString
+	var enabled bool
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return "", false, errors.New("user not found")
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return secret.String, enabled, nil
+}
+
+func (r *MFARepository) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, totp_enabled = FALSE, totp_last_counter = NULL, updated_at = $2
+		WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), secret, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on the second factor, recording counter, the time step
+// of the code that confirmed it, as used, and replaces the recovery codes.
+func (r *MFARepository) EnableTOTP(ctx context.Context, userID int, counter int64, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
+		ctx,
+		annotate(ctx, `UPDATE users SET totp_enabled = TRUE, totp_last_counter = $1, updated_at = $2 WHERE id = $3 AND totp_secret IS NOT NULL`),
+		counter,
+		time.Now(),
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	// Replace any previous set of recovery codes
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`), userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(
+			ctx,
+			annotate(ctx, `INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`),
+			userID,
+			hash,
+			time.Now(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPCounter records counter, the time step of an accepted TOTP code,
+// and returns false if that step or a later one was already used.
+func (r *MFARepository) UseTOTPCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_last_counter = $1
+		WHERE id = $2 AND (totp_last_counter IS NULL OR totp_last_counter < $1)`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), counter, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *MFARepository) GetUnusedRecoveryCodes(ctx context.Context, userID int) ([]*models.RecoveryCode, error) {
+	query := `
+		SELECT id, user_id, code_hash, used_at, created_at
+		FROM recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []*models.RecoveryCode
+	for rows.Next() {
+		code := &models.RecoveryCode{}
+		err := rows.Scan(
+			&code.ID,
+			&code.UserID,
+			&code.CodeHash,
+			&code.UsedAt,
+			&code.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (r *MFARepository) MarkRecoveryCodeUsed(ctx context.Context, id int) error {
+	query := `UPDATE recovery_codes SET used_at = $1 WHERE id = $2 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// A concurrent login already consumed this code
+	if rowsAffected == 0 {
+		return errors.New("recovery code already used")
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type OAuthRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthRepository(db *sql.DB) *OAuthRepository {
+	return &OAuthRepository{db: db}
+}
+
+func (r *OAuthRepository) CreateClient(ctx context.Context, client *models.OAuthClient) error {
+	query := `
+		INSERT INTO oauth_clients (client_id, client_secret_hash, owner_id, name, redirect_uris, scopes, confidential, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	var secretHash sql.NullString
+	if client.ClientSecretHash != "" {
+		secretHash = sql.NullString{String: client.ClientSecretHash, Valid: true}
+	}
+
+	now := time.Now()
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		client.ClientID,
+		secretHash,
+		client.OwnerID,
+		client.Name,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.Scopes),
+		client.Confidential,
+		now,
+	).Scan(&client.ID)
+
+	if err != nil {
+		return err
+	}
+
+	client.CreatedAt = now
+	return nil
+}
+
+func (r *OAuthRepository) GetClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	query := `
+		SELECT id, client_id, client_secret_hash, owner_id, name, redirect_uris, scopes, confidential, created_at
+		FROM oauth_clients
+		WHERE client_id = $1`
+
+	client := &models.OAuthClient{}
+	var secretHash sql.NullString
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), clientID).Scan(
+		&client.ID,
+		&client.ClientID,
+		&secretHash,
+		&client.OwnerID,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.Scopes),
+		&client.Confidential,
+		&client.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("client not found")
+	}
+
+	client.ClientSecretHash = secretHash.String
+	return client, err
+}
+
+func (r *OAuthRepository) GetClientsByOwner(ctx context.Context, ownerID int) ([]*models.OAuthClient, error) {
+	query := `
+		SELECT id, client_id, owner_id, name, redirect_uris, scopes, confidential, created_at
+		FROM oauth_clients
+		WHERE owner_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []*models.OAuthClient
+	for rows.Next() {
+		client := &models.OAuthClient{}
+		err := rows.Scan(
+			&client.ID,
+			&client.ClientID,
+			&client.OwnerID,
+			&client.Name,
+			pq.Array(&client.RedirectURIs),
+			pq.Array(&client.Scopes),
+			&client.Confidential,
+			&client.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (r *OAuthRepository) DeleteClient(ctx context.Context, id, ownerID int) error {
+	query := `DELETE FROM oauth_clients WHERE id = $1 AND owner_id = $2`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("client not found or unauthorized")
+	}
+
+	return nil
+}
+
+func (r *OAuthRepository) CreateAuthorizationCode(ctx context.Context, code *models.OAuthAuthorizationCode) error {
+	query := `
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	now := time.Now()
+	_, err := r.db.ExecContext(
+		ctx,
+		annotate(ctx, query),
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		pq.Array(code.Scopes),
+		code.CodeChallenge,
+		code.ExpiresAt,
+		now,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	code.CreatedAt = now
+	return nil
+}
+
+// ConsumeAuthorizationCode deletes and returns the code in one statement,
+// so a code can be redeemed at most once even under concurrent requests.
+func (r *OAuthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.OAuthAuthorizationCode, error) {
+	query := `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at, created_at`
+
+	code := &models.OAuthAuthorizationCode{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), codeHash).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		pq.Array(&code.Scopes),
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("authorization code not found")
+	}
+
+	return code, err
+}
+
+func (r *OAuthRepository) CreateAccessToken(ctx context.Context, token *models.OAuthAccessToken) error {
+	query := `
+		INSERT INTO oauth_access_tokens (token_hash, client_id, user_id, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		token.TokenHash,
+		token.ClientID,
+		token.UserID,
+		pq.Array(token.Scopes),
+		token.ExpiresAt,
+		now,
+	).Scan(&token.ID)
+
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = now
+	return nil
+}
+
+func (r *OAuthRepository) GetAccessTokenByHash(ctx context.Context, tokenHash string) (*models.OAuthAccessToken, error) {
+	query := `
+		SELECT id, token_hash, client_id, user_id, scopes, expires_at, revoked_at, created_at
+		FROM oauth_access_tokens
+		WHERE token_hash = $1`
+
+	token := &models.OAuthAccessToken{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), tokenHash).Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.ClientID,
+		&token.UserID,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("token not found")
+	}
+
+	return token, err
+}
+
+func (r *OAuthRepository) RevokeAccessToken(ctx context.Context, tokenHash, clientID string) error {
+	query := `
+		UPDATE oauth_access_tokens
+		SET revoked_at = $1
+		WHERE token_hash = $2 AND client_id = $3 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), time.Now(), tokenHash, clientID)
+	return err
+}
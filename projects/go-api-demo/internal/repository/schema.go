@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SchemaVersion is the migration the repositories are written against.
+// Bump it with every new migration in migrations/.
+const SchemaVersion = 16
+
+// CurrentSchemaVersion returns the highest migration recorded in
+// schema_migrations.
+func CurrentSchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, annotate(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)).Scan(&version)
+	return version, err
+}
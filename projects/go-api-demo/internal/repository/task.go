@@ -1,40 +1,61 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/malex1718/go-api-demo/internal/models"
 )
 
+// TaskRepository is tenant-scoped: apart from the privacy export and the
+// assigned-to-me view, every query is limited to a single workspace, and
+// callers are expected to have checked the user's access to that workspace
+// first.
+//
+// As a second line of defense every query runs in a transaction bound to
+// the acting user (see ForUser), where Postgres row-level security hides
+// the rows of workspaces they do not belong to.
 type TaskRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	userID int
 }
 
 func NewTaskRepository(db *sql.DB) *TaskRepository {
 	return &TaskRepository{db: db}
 }
 
-func (r *TaskRepository) Create(task *models.Task) error {
+// ForUser returns a repository whose queries act on behalf of userID.
+func (r *TaskRepository) ForUser(userID int) *TaskRepository {
+	return &TaskRepository{db: r.db, userID: userID}
+}
+
+func (r *TaskRepository) Create(ctx context.Context, task *models.Task) error {
 	query := `
-		INSERT INTO tasks (title, description, status, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO tasks (title, description, status, user_id, workspace_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id`
 	
 	now := time.Now()
-	err := r.db.QueryRow(
-		query,
-		task.Title,
-		task.Description,
-		task.Status,
-		task.UserID,
-		now,
-		now,
-	).Scan(&task.ID)
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(
+			ctx,
+			annotate(ctx, query),
+			task.Title,
+			task.Description,
+			task.Status,
+			task.UserID,
+			task.WorkspaceID,
+			now,
+			now,
+		).Scan(&task.ID)
+	})
 	
 	if err != nil {
-		return err
+		return quotaError(err)
 	}
 	
 	task.CreatedAt = now
@@ -42,201 +63,380 @@ func (r *TaskRepository) Create(task *models.Task) error {
 	return nil
 }
 
-func (r *TaskRepository) GetByID(id int) (*models.Task, error) {
+func (r *TaskRepository) GetByID(ctx context.Context, id, workspaceID int) (*models.Task, error) {
 	query := `
-		SELECT id, title, description, status, user_id, created_at, updated_at
+		SELECT id, title, description, status, user_id, workspace_id, assignee_id, assigned_at, created_at, updated_at
 		FROM tasks
-		WHERE id = $1`
+		WHERE id = $1 AND workspace_id = $2`
 	
 	task := &models.Task{}
-	err := r.db.QueryRow(query, id).Scan(
-		&task.ID,
-		&task.Title,
-		&task.Description,
-		&task.Status,
-		&task.UserID,
-		&task.CreatedAt,
-		&task.UpdatedAt,
-	)
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, annotate(ctx, query), id, workspaceID).Scan(
+			&task.ID,
+			&task.Title,
+			&task.Description,
+			&task.Status,
+			&task.UserID,
+			&task.WorkspaceID,
+			&task.AssigneeID,
+			&task.AssignedAt,
+			&task.CreatedAt,
+			&task.UpdatedAt,
+		)
+	})
 	
 	if err == sql.ErrNoRows {
-		return nil, errors.New("task not found")
+		return nil, errors.New("task not found or unauthorized")
 	}
 	
 	return task, err
 }
 
-func (r *TaskRepository) GetByUserID(userID int) ([]*models.Task, error) {
+func (r *TaskRepository) GetByWorkspace(ctx context.Context, workspaceID int) ([]*models.Task, error) {
 	query := `
-		SELECT id, title, description, status, user_id, created_at, updated_at
+		SELECT id, title, description, status, user_id, workspace_id, assignee_id, assigned_at, created_at, updated_at
+		FROM tasks
+		WHERE workspace_id = $1
+		ORDER BY created_at DESC`
+	
+	return r.queryTasks(ctx, query, workspaceID)
+}
+
+// GetByUserID returns every task the user created, across workspaces. It
+// is only meant for the user's own data export.
+func (r *TaskRepository) GetByUserID(ctx context.Context, userID int) ([]*models.Task, error) {
+	query := `
+		SELECT id, title, description, status, user_id, workspace_id, assignee_id, assigned_at, created_at, updated_at
 		FROM tasks
 		WHERE user_id = $1
 		ORDER BY created_at DESC`
 	
-	rows, err := r.db.Query(query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return r.queryTasks(ctx, query, userID)
+}
+
+// GetFiltered lists the workspace's tasks matching the status and
+// assignee criteria of filter.
+func (r *TaskRepository) GetFiltered(ctx context.Context, workspaceID int, filter models.TaskFilter) ([]*models.Task, error) {
+	where, args := taskFilterClause(workspaceID, filter)
+	query := `
+		SELECT id, title, description, status, user_id, workspace_id, assignee_id, assigned_at, created_at, updated_at
+		FROM tasks
+		WHERE ` + where + `
+		ORDER BY created_at DESC`
 	
-	var tasks []*models.Task
-	for rows.Next() {
-		task := &models.Task{}
-		err := rows.Scan(
-			&task.ID,
-			&task.Title,
-			&task.Description,
-			&task.Status,
-			&task.UserID,
-			&task.CreatedAt,
-			&task.UpdatedAt,
+	return r.queryTasks(ctx, query, args...)
+}
+
+// GetAssignedTo returns the tasks assigned to the user in every workspace
+// they are still a member of. The tenant role cannot read workspace_members,
+// so membership goes through app_workspace_ids like the RLS policies.
+func (r *TaskRepository) GetAssignedTo(ctx context.Context, userID int) ([]*models.Task, error) {
+	query := `
+		SELECT id, title, description, status, user_id, workspace_id, assignee_id, assigned_at, created_at, updated_at
+		FROM tasks
+		WHERE assignee_id = $1 AND workspace_id IN (SELECT app_workspace_ids(false))
+		ORDER BY created_at DESC`
+	
+	return r.queryTasks(ctx, query, userID)
+}
+
+func (r *TaskRepository) Update(ctx context.Context, task *models.Task) error {
+	query := `
+		UPDATE tasks
+		SET title = $1, description = $2, status = $3, updated_at = $4
+		WHERE id = $5 AND workspace_id = $6`
+	
+	task.UpdatedAt = time.Now()
+	return r.inTenant(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(
+			ctx,
+			annotate(ctx, query),
+			task.Title,
+			task.Description,
+			task.Status,
+			task.UpdatedAt,
+			task.ID,
+			task.WorkspaceID,
 		)
+	
 		if err != nil {
-			return nil, err
+			return err
 		}
-		tasks = append(tasks, task)
-	}
 	
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
 	
-	return tasks, nil
+		if rowsAffected == 0 {
+			return errors.New("task not found or unauthorized")
+		}
+	
+		return nil
+	})
 }
 
-func (r *TaskRepository) GetByStatus(userID int, status string) ([]*models.Task, error) {
+func (r *TaskRepository) Delete(ctx context.Context, id, workspaceID int) error {
+	query := `DELETE FROM tasks WHERE id = $1 AND workspace_id = $2`
+	
+	return r.inTenant(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, annotate(ctx, query), id, workspaceID)
+		if err != nil {
+			return err
+		}
+	
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	
+		if rowsAffected == 0 {
+			return errors.New("task not found or unauthorized")
+		}
+	
+		return nil
+	})
+}
+
+func (r *TaskRepository) CountByStatus(ctx context.Context, workspaceID int, filter models.TaskFilter) (map[string]int, error) {
+	where, args := taskFilterClause(workspaceID, filter)
 	query := `
-		SELECT id, title, description, status, user_id, created_at, updated_at
+		SELECT status, COUNT(*) as count
 		FROM tasks
-		WHERE user_id = $1 AND status = $2
-		ORDER BY created_at DESC`
+		WHERE ` + where + `
+		GROUP BY status`
+	
+	statusCounts := make(map[string]int)
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, annotate(ctx, query), args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+	
+		for rows.Next() {
+			var status string
+			var count int
+			err := rows.Scan(&status, &count)
+			if err != nil {
+				return err
+			}
+			statusCounts[status] = count
+		}
+	
+		return rows.Err()
+	})
 	
-	rows, err := r.db.Query(query, userID, status)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 	
-	var tasks []*models.Task
-	for rows.Next() {
-		task := &models.Task{}
-		err := rows.Scan(
-			&task.ID,
-			&task.Title,
-			&task.Description,
-			&task.Status,
-			&task.UserID,
-			&task.CreatedAt,
-			&task.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
+	// Ensure all statuses are present
+	for _, status := range []string{"pending", "in_progress", "completed"} {
+		if _, exists := statusCounts[status]; !exists {
+			statusCounts[status] = 0
 		}
-		tasks = append(tasks, task)
-	}
-	
-	if err = rows.Err(); err != nil {
-		return nil, err
 	}
 	
-	return tasks, nil
+	return statusCounts, nil
 }
 
-func (r *TaskRepository) Update(task *models.Task) error {
+// CountByAssignee groups the workspace's task counts by assignee and
+// status. Unassigned tasks are keyed "none", the rest by assignee id.
+func (r *TaskRepository) CountByAssignee(ctx context.Context, workspaceID int) (map[string]map[string]int, error) {
 	query := `
-		UPDATE tasks
-		SET title = $1, description = $2, status = $3, updated_at = $4
-		WHERE id = $5 AND user_id = $6`
+		SELECT COALESCE(assignee_id::text, 'none'), status, COUNT(*) as count
+		FROM tasks
+		WHERE workspace_id = $1
+		GROUP BY assignee_id, status`
 	
-	task.UpdatedAt = time.Now()
-	result, err := r.db.Exec(
-		query,
-		task.Title,
-		task.Description,
-		task.Status,
-		task.UpdatedAt,
-		task.ID,
-		task.UserID,
-	)
+	counts := make(map[string]map[string]int)
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, annotate(ctx, query), workspaceID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
 	
-	if err != nil {
-		return err
-	}
+		for rows.Next() {
+			var assignee, status string
+			var count int
+			err := rows.Scan(&assignee, &status, &count)
+			if err != nil {
+				return err
+			}
+			if counts[assignee] == nil {
+				counts[assignee] = make(map[string]int)
+			}
+			counts[assignee][status] = count
+		}
 	
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
+		return rows.Err()
+	})
 	
-	if rowsAffected == 0 {
-		return errors.New("task not found or unauthorized")
+	if err != nil {
+		return nil, err
 	}
 	
-	return nil
+	return counts, nil
 }
 
-func (r *TaskRepository) Delete(id, userID int) error {
-	query := `DELETE FROM tasks WHERE id = $1 AND user_id = $2`
+// Assign sets or clears the task's assignee and appends the change to the
+// assignment history.
+func (r *TaskRepository) Assign(ctx context.Context, task *models.Task, assignedBy int) error {
+	now := time.Now()
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, annotate(ctx, `
+			UPDATE tasks
+			SET assignee_id = $1, assigned_at = $2, updated_at = $2
+			WHERE id = $3 AND workspace_id = $4`),
+			task.AssigneeID,
+			now,
+			task.ID,
+			task.WorkspaceID,
+		)
+		if err != nil {
+			return err
+		}
 	
-	result, err := r.db.Exec(query, id, userID)
-	if err != nil {
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	
+		if rowsAffected == 0 {
+			return errors.New("task not found or unauthorized")
+		}
+	
+		_, err = tx.ExecContext(ctx, annotate(ctx, `
+			INSERT INTO task_assignments (task_id, assignee_id, assigned_by, assigned_at)
+			VALUES ($1, $2, $3, $4)`),
+			task.ID,
+			task.AssigneeID,
+			assignedBy,
+			now,
+		)
 		return err
-	}
+	})
 	
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 	
-	if rowsAffected == 0 {
-		return errors.New("task not found or unauthorized")
-	}
-	
+	task.AssignedAt = &now
+	task.UpdatedAt = now
 	return nil
 }
 
-func (r *TaskRepository) CountByStatus(userID int) (map[string]int, error) {
+// GetAssignments returns the assignment history of a task, newest first.
+func (r *TaskRepository) GetAssignments(ctx context.Context, taskID, workspaceID int) ([]*models.TaskAssignment, error) {
 	query := `
-		SELECT status, COUNT(*) as count
-		FROM tasks
-		WHERE user_id = $1
-		GROUP BY status`
+		SELECT a.id, a.task_id, a.assignee_id, a.assigned_by, a.assigned_at
+		FROM task_assignments a
+		JOIN tasks t ON t.id = a.task_id
+		WHERE a.task_id = $1 AND t.workspace_id = $2
+		ORDER BY a.assigned_at DESC`
+	
+	var assignments []*models.TaskAssignment
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, annotate(ctx, query), taskID, workspaceID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+	
+		for rows.Next() {
+			assignment := &models.TaskAssignment{}
+			err := rows.Scan(
+				&assignment.ID,
+				&assignment.TaskID,
+				&assignment.AssigneeID,
+				&assignment.AssignedBy,
+				&assignment.AssignedAt,
+			)
+			if err != nil {
+				return err
+			}
+			assignments = append(assignments, assignment)
+		}
+	
+		return rows.Err()
+	})
 	
-	rows, err := r.db.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 	
-	statusCounts := make(map[string]int)
-	for rows.Next() {
-		var status string
-		var count int
-		err := rows.Scan(&status, &count)
-		if err != nil {
-			return nil, err
-		}
-		statusCounts[status] = count
-	}
+	return assignments, nil
+}
+
+// taskFilterClause builds the WHERE clause for a workspace-scoped query.
+func taskFilterClause(workspaceID int, filter models.TaskFilter) (string, []interface{}) {
+	conditions := []string{"workspace_id = $1"}
+	args := []interface{}{workspaceID}
 	
-	if err = rows.Err(); err != nil {
-		return nil, err
+	if filter.Status != "" {
+		args = append(args, string(filter.Status))
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.AssigneeID != nil {
+		args = append(args, *filter.AssigneeID)
+		conditions = append(conditions, fmt.Sprintf("assignee_id = $%d", len(args)))
+	}
+	if filter.Unassigned {
+		conditions = append(conditions, "assignee_id IS NULL")
+	}
+	if filter.CreatedBy != nil {
+		args = append(args, *filter.CreatedBy)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
 	}
 	
-	// Ensure all statuses are present
-	for _, status := range []string{"pending", "in_progress", "completed"} {
-		if _, exists := statusCounts[status]; !exists {
-			statusCounts[status] = 0
+	return strings.Join(conditions, " AND "), args
+}
+
+func (r *TaskRepository) queryTasks(ctx context.Context, query string, args ...interface{}) ([]*models.Task, error) {
+	var tasks []*models.Task
+	err := r.inTenant(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, annotate(ctx, query), args...)
+		if err != nil {
+			return err
 		}
+		defer rows.Close()
+	
+		for rows.Next() {
+			task := &models.Task{}
+			err := rows.Scan(
+				&task.ID,
+				&task.Title,
+				&task.Description,
+				&task.Status,
+				&task.UserID,
+				&task.WorkspaceID,
+				&task.AssigneeID,
+				&task.AssignedAt,
+				&task.CreatedAt,
+				&task.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+		}
+	
+		return rows.Err()
+	})
+	
+	if err != nil {
+		return nil, err
 	}
 	
-	return statusCounts, nil
+	return tasks, nil
 }
 
-func (r *TaskRepository) BelongsToUser(taskID, userID int) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM tasks WHERE id = $1 AND user_id = $2)`
-	
-	var exists bool
-	err := r.db.QueryRow(query, taskID, userID).Scan(&exists)
-	return exists, err
+// inTenant runs fn in a transaction bound to the repository's user.
+func (r *TaskRepository) inTenant(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if r.userID == 0 {
+		return errors.New("task repository used without a user")
+	}
+	return withTenant(ctx, r.db, r.userID, fn)
 }
\ No newline at end of file
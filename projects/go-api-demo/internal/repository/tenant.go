@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+// tenantRole is the database role that row-level security policies apply
+// to (see migrations/012_row_level_security.sql). Switching to it keeps the
+// policies in force even when the API connects as the table owner or a
+// superuser.
+const tenantRole = "app_tenant"
+
+// withTenant runs fn in a transaction acting as userID: the role is
+// switched to tenantRole and app.user_id is set for the transaction only,
+// so a pooled connection never carries one request's identity into the
+// next.
+func withTenant(ctx context.Context, db *sql.DB, userID int, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setTenant(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setTenant(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `SET LOCAL ROLE `+tenantRole)); err != nil {
+		return err
+	}
+
+	// SET LOCAL does not take bind parameters; set_config with is_local is
+	// the same statement in function form
+	_, err := tx.ExecContext(ctx, annotate(ctx, `SELECT set_config('app.user_id', $1, true)`), strconv.Itoa(userID))
+	return err
+}
+
+// quotaErrorCode is raised by the quota triggers of
+// migrations/013_tenants.sql.
+const quotaErrorCode = "TQ001"
+
+// quotaError turns a tenant quota violation into the plain error handlers
+// map to a status code, and leaves any other error untouched.
+func quotaError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == quotaErrorCode {
+		return errors.New(pqErr.Message)
+	}
+	return err
+}
+
+// TenantRepository manages the tenant registry in the public schema. It is
+// used by the tenant resolver and the tenant-admin command, never from a
+// tenant's own connection.
+type TenantRepository struct {
+	db *sql.DB
+}
+
+func NewTenantRepository(db *sql.DB) *TenantRepository {
+	return &TenantRepository{db: db}
+}
+
+func (r *TenantRepository) Create(ctx context.Context, tenant *models.Tenant) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertTenant(ctx, tx, tenant); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateWithSchema registers the tenant and builds its schema by running
+// the migrations inside it, all in one transaction so a failed migration
+// leaves nothing behind.
+func (r *TenantRepository) CreateWithSchema(ctx context.Context, tenant *models.Tenant, migrations []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertTenant(ctx, tx, tenant); err != nil {
+		return err
+	}
+
+	schema := pq.QuoteIdentifier(tenant.SchemaName)
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `CREATE SCHEMA `+schema)); err != nil {
+		return err
+	}
+
+	// Unqualified names in the migrations now resolve to the new schema,
+	// and tenant_id defaults to the new tenant
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `SELECT set_config('search_path', $1, true)`), tenant.SchemaName+", public"); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `SELECT set_config('app.tenant_id', $1, true)`), strconv.Itoa(tenant.ID)); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, err := tx.ExecContext(ctx, migration); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `GRANT USAGE ON SCHEMA `+schema+` TO `+tenantRole)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TenantRepository) GetByID(ctx context.Context, id int) (*models.Tenant, error) {
+	return r.getOne(ctx, `WHERE id = $1`, id)
+}
+
+func (r *TenantRepository) GetBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	return r.getOne(ctx, `WHERE slug = $1`, slug)
+}
+
+func (r *TenantRepository) List(ctx context.Context) ([]*models.Tenant, error) {
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, `
+		SELECT id, slug, name, status, schema_name, max_users, max_tasks, rate_limit, user_count, task_count, created_at, updated_at
+		FROM tenants
+		ORDER BY slug`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []*models.Tenant
+	for rows.Next() {
+		tenant, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, rows.Err()
+}
+
+func (r *TenantRepository) UpdateStatus(ctx context.Context, id int, status string) error {
+	return r.exec(ctx, `UPDATE tenants SET status = $1 WHERE id = $2`, status, id)
+}
+
+// UpdateLimits stores the tenant's quotas and rate limit. Lowering a quota
+// below the current usage only blocks new rows; nothing is removed.
+func (r *TenantRepository) UpdateLimits(ctx context.Context, tenant *models.Tenant) error {
+	return r.exec(ctx, `
+		UPDATE tenants
+		SET max_users = $1, max_tasks = $2, rate_limit = $3
+		WHERE id = $4`,
+		tenant.MaxUsers,
+		tenant.MaxTasks,
+		tenant.RateLimit,
+		tenant.ID,
+	)
+}
+
+// Delete removes the tenant and all of its data: its schema in
+// schema-per-tenant mode, or its rows in the shared tables through the
+// tenant_id foreign keys.
+func (r *TenantRepository) Delete(ctx context.Context, tenant *models.Tenant) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if tenant.SchemaName != "" {
+		if _, err := tx.ExecContext(ctx, annotate(ctx, `DROP SCHEMA IF EXISTS `+pq.QuoteIdentifier(tenant.SchemaName)+` CASCADE`)); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, annotate(ctx, `DELETE FROM tenants WHERE id = $1`), tenant.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("tenant not found")
+	}
+
+	return tx.Commit()
+}
+
+func (r *TenantRepository) getOne(ctx context.Context, where string, arg interface{}) (*models.Tenant, error) {
+	row := r.db.QueryRowContext(ctx, annotate(ctx, `
+		SELECT id, slug, name, status, schema_name, max_users, max_tasks, rate_limit, user_count, task_count, created_at, updated_at
+		FROM tenants
+		`+where), arg)
+
+	tenant, err := scanTenant(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("tenant not found")
+	}
+
+	return tenant, err
+}
+
+func (r *TenantRepository) exec(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("tenant not found")
+	}
+
+	return nil
+}
+
+func insertTenant(ctx context.Context, tx *sql.Tx, tenant *models.Tenant) error {
+	var schemaName sql.NullString
+	if tenant.SchemaName != "" {
+		schemaName = sql.NullString{String: tenant.SchemaName, Valid: true}
+	}
+
+	now := time.Now()
+	err := tx.QueryRowContext(ctx, annotate(ctx, `
+		INSERT INTO tenants (slug, name, status, schema_name, max_users, max_tasks, rate_limit, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`),
+		tenant.Slug,
+		tenant.Name,
+		tenant.Status,
+		schemaName,
+		tenant.MaxUsers,
+		tenant.MaxTasks,
+		tenant.RateLimit,
+		now,
+		now,
+	).Scan(&tenant.ID)
+
+	if err != nil {
+		return err
+	}
+
+	tenant.CreatedAt = now
+	tenant.UpdatedAt = now
+	return nil
+}
+
+type tenantScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTenant(row tenantScanner) (*models.Tenant, error) {
+	tenant := &models.Tenant{}
+	var schemaName sql.NullString
+	err := row.Scan(
+		&tenant.ID,
+		&tenant.Slug,
+		&tenant.Name,
+		&tenant.Status,
+		&schemaName,
+		&tenant.MaxUsers,
+		&tenant.MaxTasks,
+		&tenant.RateLimit,
+		&tenant.UserCount,
+		&tenant.TaskCount,
+		&tenant.CreatedAt,
+		&tenant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant.SchemaName = schemaName.String
+	return tenant, nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		token.UserID,
+		token.Name,
+		token.Prefix,
+		token.TokenHash,
+		pq.Array(token.Scopes),
+		token.ExpiresAt,
+		now,
+	).Scan(&token.ID)
+
+	if err != nil {
+		return err
+	}
+
+	token.CreatedAt = now
+	return nil
+}
+
+func (r *TokenRepository) GetByHash(ctx context.Context, hash string) (*models.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1`
+
+	token := &models.PersonalAccessToken{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.Prefix,
+		&token.TokenHash,
+		pq.Array(&token.Scopes),
+		&token.ExpiresAt,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("token not found")
+	}
+
+	return token, err
+}
+
+func (r *TokenRepository) GetByUserID(ctx context.Context, userID int) ([]*models.PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_prefix, token_hash, scopes, expires_at, last_used_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*models.PersonalAccessToken
+	for rows.Next() {
+		token := &models.PersonalAccessToken{}
+		err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			&token.Prefix,
+			&token.TokenHash,
+			pq.Array(&token.Scopes),
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *TokenRepository) UpdateLastUsed(ctx context.Context, id int, usedAt time.Time) error {
+	query := `UPDATE personal_access_tokens SET last_used_at = $1 WHERE id = $2`
+
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), usedAt, id)
+	return err
+}
+
+func (r *TokenRepository) Delete(ctx context.Context, id, userID int) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("token not found or unauthorized")
+	}
+
+	return nil
+}
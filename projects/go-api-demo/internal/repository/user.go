@@ -1,13 +1,17 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/malex1718/go-api-demo/internal/models"
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -16,24 +20,31 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (username, email, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (username, email, password_hash, name, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id`
 	
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	
 	now := time.Now()
-	err := r.db.QueryRow(
-		query,
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
 		user.Username,
 		user.Email,
 		user.PasswordHash,
+		user.Name,
+		user.Role,
 		now,
 		now,
 	).Scan(&user.ID)
 	
 	if err != nil {
-		return err
+		return quotaError(err)
 	}
 	
 	user.CreatedAt = now
@@ -41,18 +52,26 @@ func (r *UserRepository) Create(user *models.User) error {
 	return nil
 }
 
-func (r *UserRepository) GetByID(id int) (*models.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT id, username, email, password_hash, name, avatar_url, role, disabled, password_reset_required, sessions_revoked_at, deletion_scheduled_at, created_at, updated_at
 		FROM users
 		WHERE id = $1`
 	
 	user := &models.User{}
-	err := r.db.QueryRow(query, id).Scan(
+	var avatarURL sql.NullString
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Name,
+		&avatarURL,
+		&user.Role,
+		&user.Disabled,
+		&user.PasswordResetRequired,
+		&user.SessionsRevokedAt,
+		&user.DeletionScheduledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -61,21 +80,30 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 		return nil, errors.New("user not found")
 	}
 	
+	user.AvatarURL = avatarURL.String
 	return user, err
 }
 
-func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT id, username, email, password_hash, name, avatar_url, role, disabled, password_reset_required, sessions_revoked_at, deletion_scheduled_at, created_at, updated_at
 		FROM users
 		WHERE username = $1`
 	
 	user := &models.User{}
-	err := r.db.QueryRow(query, username).Scan(
+	var avatarURL sql.NullString
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Name,
+		&avatarURL,
+		&user.Role,
+		&user.Disabled,
+		&user.PasswordResetRequired,
+		&user.SessionsRevokedAt,
+		&user.DeletionScheduledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -84,21 +112,30 @@ func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 		return nil, errors.New("user not found")
 	}
 	
+	user.AvatarURL = avatarURL.String
 	return user, err
 }
 
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, password_hash, created_at, updated_at
+		SELECT id, username, email, password_hash, name, avatar_url, role, disabled, password_reset_required, sessions_revoked_at, deletion_scheduled_at, created_at, updated_at
 		FROM users
 		WHERE email = $1`
 	
 	user := &models.User{}
-	err := r.db.QueryRow(query, email).Scan(
+	var avatarURL sql.NullString
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), email).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Name,
+		&avatarURL,
+		&user.Role,
+		&user.Disabled,
+		&user.PasswordResetRequired,
+		&user.SessionsRevokedAt,
+		&user.DeletionScheduledAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -107,21 +144,25 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 		return nil, errors.New("user not found")
 	}
 	
+	user.AvatarURL = avatarURL.String
 	return user, err
 }
 
-func (r *UserRepository) Update(user *models.User) error {
+// Update saves the profile fields. The password hash is only written by
+// UpdatePasswordHash, so a profile edit cannot restore a stale one.
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, password_hash = $3, updated_at = $4
+		SET username = $1, email = $2, name = $3, updated_at = $4
 		WHERE id = $5`
 	
 	user.UpdatedAt = time.Now()
-	result, err := r.db.Exec(
-		query,
+	result, err := r.db.ExecContext(
+		ctx,
+		annotate(ctx, query),
 		user.Username,
 		user.Email,
-		user.PasswordHash,
+		user.Name,
 		user.UpdatedAt,
 		user.ID,
 	)
@@ -142,10 +183,242 @@ func (r *UserRepository) Update(user *models.User) error {
 	return nil
 }
 
-func (r *UserRepository) Delete(id int) error {
+func (r *UserRepository) UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1, updated_at = $2 WHERE id = $3`
+	
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), passwordHash, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return nil
+}
+
+// List returns one page of users, optionally filtered by a search term
+// matched against username, email and name, plus the total match count.
+func (r *UserRepository) List(ctx context.Context, search string, limit, offset int) ([]*models.User, int, error) {
+	pattern := "%" + likeEscaper.Replace(search) + "%"
+	
+	var total int
+	err := r.db.QueryRowContext(ctx, annotate(ctx, `
+		SELECT COUNT(*)
+		FROM users
+		WHERE $1 = '' OR username ILIKE $2 OR email ILIKE $2 OR name ILIKE $2`), search, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	query := `
+		SELECT id, username, email, name, avatar_url, role, disabled, password_reset_required, deletion_scheduled_at, created_at, updated_at
+		FROM users
+		WHERE $1 = '' OR username ILIKE $2 OR email ILIKE $2 OR name ILIKE $2
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`
+	
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), search, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		var avatarURL sql.NullString
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.Name,
+			&avatarURL,
+			&user.Role,
+			&user.Disabled,
+			&user.PasswordResetRequired,
+			&user.DeletionScheduledAt,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		user.AvatarURL = avatarURL.String
+		users = append(users, user)
+	}
+	
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	
+	return users, total, nil
+}
+
+func (r *UserRepository) UpdateRoleAndStatus(ctx context.Context, id int, role string, disabled bool) error {
+	query := `UPDATE users SET role = $1, disabled = $2, updated_at = $3 WHERE id = $4`
+	
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), role, disabled, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return nil
+}
+
+// ForcePasswordReset replaces the password, flags the account so the user
+// must choose a new one, and ends every open session.
+func (r *UserRepository) ForcePasswordReset(ctx context.Context, id int, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, password_reset_required = TRUE, sessions_revoked_at = $2, updated_at = $2
+		WHERE id = $3`
+	
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), passwordHash, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return nil
+}
+
+func (r *UserRepository) ClearPasswordReset(ctx context.Context, id int) error {
+	query := `UPDATE users SET password_reset_required = FALSE, updated_at = $1 WHERE id = $2`
+	
+	_, err := r.db.ExecContext(ctx, annotate(ctx, query), time.Now(), id)
+	return err
+}
+
+func (r *UserRepository) RevokeSessions(ctx context.Context, id int) error {
+	query := `UPDATE users SET sessions_revoked_at = $1, updated_at = $1 WHERE id = $2`
+	
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), time.Now(), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return nil
+}
+
+func (r *UserRepository) AdminExists(ctx context.Context) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE role = 'admin')`
+	
+	var exists bool
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query)).Scan(&exists)
+	return exists, err
+}
+
+func (r *UserRepository) UpdateAvatar(ctx context.Context, id int, avatarURL string) error {
+	query := `UPDATE users SET avatar_url = NULLIF($1, ''), updated_at = $2 WHERE id = $3`
+	
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), avatarURL, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return nil
+}
+
+// ScheduleDeletion marks the account for deletion at the given time; a nil
+// time cancels a pending deletion.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id int, at *time.Time) error {
+	query := `UPDATE users SET deletion_scheduled_at = $1, updated_at = $2 WHERE id = $3`
+	
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), at, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return nil
+}
+
+// GetDueForDeletion returns the users whose grace period ended before the
+// given time.
+func (r *UserRepository) GetDueForDeletion(ctx context.Context, before time.Time) ([]*models.User, error) {
+	query := `
+		SELECT id, username, avatar_url
+		FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= $1`
+	
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		var avatarURL sql.NullString
+		if err := rows.Scan(&user.ID, &user.Username, &avatarURL); err != nil {
+			return nil, err
+		}
+		user.AvatarURL = avatarURL.String
+		users = append(users, user)
+	}
+	
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	return users, nil
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM users WHERE id = $1`
 	
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), id)
 	if err != nil {
 		return err
 	}
@@ -162,18 +435,65 @@ func (r *UserRepository) Delete(id int) error {
 	return nil
 }
 
-func (r *UserRepository) UsernameExists(username string) (bool, error) {
+// Erase hard-deletes a user. Rows owned by the user go with it through
+// ON DELETE CASCADE; the tasks they created in shared workspaces stay with
+// no creator, and the security audit trail is kept for compliance but
+// stripped of anything that identifies the person.
+func (r *UserRepository) Erase(ctx context.Context, id int, username string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	_, err = tx.ExecContext(ctx, annotate(ctx, `
+		UPDATE audit_events
+		SET user_id = NULL, ip_address = NULL, metadata = '{}'
+		WHERE user_id = $1 OR lower(metadata->>'username') = lower($2)`), id, username)
+	if err != nil {
+		return err
+	}
+	
+	_, err = tx.ExecContext(ctx, annotate(ctx, `DELETE FROM login_attempts WHERE key = $1`), "user:"+strings.ToLower(username))
+	if err != nil {
+		return err
+	}
+	
+	// Shared workspaces outlive their creator; the personal one does not
+	_, err = tx.ExecContext(ctx, annotate(ctx, `DELETE FROM workspaces WHERE personal AND created_by = $1`), id)
+	if err != nil {
+		return err
+	}
+	
+	result, err := tx.ExecContext(ctx, annotate(ctx, `DELETE FROM users WHERE id = $1`), id)
+	if err != nil {
+		return err
+	}
+	
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	
+	return tx.Commit()
+}
+
+func (r *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`
 	
 	var exists bool
-	err := r.db.QueryRow(query, username).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), username).Scan(&exists)
 	return exists, err
 }
 
-func (r *UserRepository) EmailExists(email string) (bool, error) {
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
 	
 	var exists bool
-	err := r.db.QueryRow(query, email).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), email).Scan(&exists)
 	return exists, err
 }
\ No newline at end of file
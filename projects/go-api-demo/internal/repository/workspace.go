@@ -0,0 +1,481 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+)
+
+type WorkspaceRepository struct {
+	db *sql.DB
+}
+
+func NewWorkspaceRepository(db *sql.DB) *WorkspaceRepository {
+	return &WorkspaceRepository{db: db}
+}
+
+// Create inserts the workspace and makes ownerID its owner.
+func (r *WorkspaceRepository) Create(ctx context.Context, workspace *models.Workspace, ownerID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	err = tx.QueryRowContext(ctx, annotate(ctx, `
+		INSERT INTO workspaces (name, personal, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`),
+		workspace.Name,
+		workspace.Personal,
+		ownerID,
+		now,
+		now,
+	).Scan(&workspace.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, annotate(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)`),
+		workspace.ID,
+		ownerID,
+		models.WorkspaceRoleOwner,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	workspace.CreatedBy = &ownerID
+	workspace.CreatedAt = now
+	workspace.UpdatedAt = now
+	return nil
+}
+
+func (r *WorkspaceRepository) GetByID(ctx context.Context, id int) (*models.Workspace, error) {
+	query := `
+		SELECT id, name, personal, created_by, created_at, updated_at
+		FROM workspaces
+		WHERE id = $1`
+
+	workspace := &models.Workspace{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), id).Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.Personal,
+		&workspace.CreatedBy,
+		&workspace.CreatedAt,
+		&workspace.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("workspace not found")
+	}
+
+	return workspace, err
+}
+
+func (r *WorkspaceRepository) GetPersonal(ctx context.Context, userID int) (*models.Workspace, error) {
+	query := `
+		SELECT id, name, personal, created_by, created_at, updated_at
+		FROM workspaces
+		WHERE personal AND created_by = $1`
+
+	workspace := &models.Workspace{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), userID).Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.Personal,
+		&workspace.CreatedBy,
+		&workspace.CreatedAt,
+		&workspace.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("workspace not found")
+	}
+
+	return workspace, err
+}
+
+func (r *WorkspaceRepository) GetByMember(ctx context.Context, userID int) ([]*models.WorkspaceMembership, error) {
+	query := `
+		SELECT w.id, w.name, w.personal, w.created_by, w.created_at, w.updated_at, m.role
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.personal DESC, w.name`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*models.WorkspaceMembership
+	for rows.Next() {
+		membership := &models.WorkspaceMembership{}
+		err := rows.Scan(
+			&membership.ID,
+			&membership.Name,
+			&membership.Personal,
+			&membership.CreatedBy,
+			&membership.CreatedAt,
+			&membership.UpdatedAt,
+			&membership.Role,
+		)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, membership)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+func (r *WorkspaceRepository) Rename(ctx context.Context, id int, name string) error {
+	query := `UPDATE workspaces SET name = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), name, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("workspace not found")
+	}
+
+	return nil
+}
+
+// Delete removes the workspace; its tasks, members and invitations go
+// with it.
+func (r *WorkspaceRepository) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM workspaces WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("workspace not found")
+	}
+
+	return nil
+}
+
+func (r *WorkspaceRepository) GetMemberRole(ctx context.Context, workspaceID, userID int) (string, error) {
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+
+	var role string
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), workspaceID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", errors.New("membership not found")
+	}
+
+	return role, err
+}
+
+func (r *WorkspaceRepository) GetMembers(ctx context.Context, workspaceID int) ([]*models.WorkspaceMember, error) {
+	query := `
+		SELECT m.workspace_id, m.user_id, u.username, u.email, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at`
+
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.WorkspaceMember
+	for rows.Next() {
+		member := &models.WorkspaceMember{}
+		err := rows.Scan(
+			&member.WorkspaceID,
+			&member.UserID,
+			&member.Username,
+			&member.Email,
+			&member.Role,
+			&member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *WorkspaceRepository) UpdateMemberRole(ctx context.Context, workspaceID, userID int, role string) error {
+	query := `UPDATE workspace_members SET role = $1 WHERE workspace_id = $2 AND user_id = $3`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), role, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("membership not found")
+	}
+
+	return nil
+}
+
+// RemoveMember takes the user out of the workspace and unassigns the
+// workspace tasks they were working on. The tasks are updated on behalf of
+// actorID, so row-level security applies as for any other task write.
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID, actorID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setTenant(ctx, tx, actorID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, annotate(ctx, `
+		WITH unassigned AS (
+			UPDATE tasks
+			SET assignee_id = NULL, assigned_at = $1
+			WHERE workspace_id = $2 AND assignee_id = $3
+			RETURNING id
+		)
+		INSERT INTO task_assignments (task_id, assignee_id, assigned_by, assigned_at)
+		SELECT id, NULL, $4, $1 FROM unassigned`), time.Now(), workspaceID, userID, actorID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, annotate(ctx, `RESET ROLE`)); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, annotate(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`), workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("membership not found")
+	}
+
+	return tx.Commit()
+}
+
+func (r *WorkspaceRepository) CreateInvitation(ctx context.Context, invitation *models.WorkspaceInvitation) error {
+	query := `
+		INSERT INTO workspace_invitations (workspace_id, email, role, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	now := time.Now()
+	err := r.db.QueryRowContext(
+		ctx,
+		annotate(ctx, query),
+		invitation.WorkspaceID,
+		invitation.Email,
+		invitation.Role,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+		now,
+	).Scan(&invitation.ID)
+
+	if err != nil {
+		return err
+	}
+
+	invitation.CreatedAt = now
+	return nil
+}
+
+func (r *WorkspaceRepository) GetInvitation(ctx context.Context, id int) (*models.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, accepted_at, created_at
+		FROM workspace_invitations
+		WHERE id = $1`
+
+	invitation := &models.WorkspaceInvitation{}
+	err := r.db.QueryRowContext(ctx, annotate(ctx, query), id).Scan(
+		&invitation.ID,
+		&invitation.WorkspaceID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("invitation not found")
+	}
+
+	return invitation, err
+}
+
+func (r *WorkspaceRepository) GetInvitationsByWorkspace(ctx context.Context, workspaceID int) ([]*models.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, accepted_at, created_at
+		FROM workspace_invitations
+		WHERE workspace_id = $1 AND accepted_at IS NULL
+		ORDER BY created_at DESC`
+
+	return r.queryInvitations(ctx, query, workspaceID)
+}
+
+// GetPendingInvitationsByEmail returns the open, unexpired invitations
+// addressed to an email.
+func (r *WorkspaceRepository) GetPendingInvitationsByEmail(ctx context.Context, email string) ([]*models.WorkspaceInvitation, error) {
+	query := `
+		SELECT id, workspace_id, email, role, invited_by, expires_at, accepted_at, created_at
+		FROM workspace_invitations
+		WHERE lower(email) = lower($1) AND accepted_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC`
+
+	return r.queryInvitations(ctx, query, email)
+}
+
+// AcceptInvitation adds the user to the workspace and closes the
+// invitation. An existing membership is left untouched.
+func (r *WorkspaceRepository) AcceptInvitation(ctx context.Context, invitation *models.WorkspaceInvitation, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, annotate(ctx, `
+		UPDATE workspace_invitations
+		SET accepted_at = $1
+		WHERE id = $2 AND accepted_at IS NULL`), now, invitation.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("invitation not found")
+	}
+
+	_, err = tx.ExecContext(ctx, annotate(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (workspace_id, user_id) DO NOTHING`),
+		invitation.WorkspaceID,
+		userID,
+		invitation.Role,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	invitation.AcceptedAt = &now
+	return nil
+}
+
+func (r *WorkspaceRepository) DeleteInvitation(ctx context.Context, id, workspaceID int) error {
+	query := `DELETE FROM workspace_invitations WHERE id = $1 AND workspace_id = $2`
+
+	result, err := r.db.ExecContext(ctx, annotate(ctx, query), id, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("invitation not found")
+	}
+
+	return nil
+}
+
+func (r *WorkspaceRepository) queryInvitations(ctx context.Context, query string, args ...interface{}) ([]*models.WorkspaceInvitation, error) {
+	rows, err := r.db.QueryContext(ctx, annotate(ctx, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []*models.WorkspaceInvitation
+	for rows.Next() {
+		invitation := &models.WorkspaceInvitation{}
+		err := rows.Scan(
+			&invitation.ID,
+			&invitation.WorkspaceID,
+			&invitation.Email,
+			&invitation.Role,
+			&invitation.InvitedBy,
+			&invitation.ExpiresAt,
+			&invitation.AcceptedAt,
+			&invitation.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
@@ -0,0 +1,52 @@
+// Package requestid carries the id correlating a request's responses, log
+// lines and SQL statements.
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Header is accepted from clients and proxies and echoed in responses.
+const Header = "X-Request-ID"
+
+const maxLength = 128
+
+type contextKey struct{}
+
+// New generates a request id.
+func New() string {
+	return uuid.NewString()
+}
+
+// Valid reports whether an incoming id can be reused as is. Ids end up in
+// logs, headers and SQL comments, so only short, plain values are kept.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithContext returns a copy of ctx carrying id.
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id stored in ctx, or "".
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
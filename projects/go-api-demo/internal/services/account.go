@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/logging"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+// purgeTimeout bounds one round of the background purge.
+const purgeTimeout = 5 * time.Minute
+
+// avatarTypes maps the accepted image types, as sniffed from the upload
+// itself, to the file extension they are stored with.
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type AccountPolicy struct {
+	// AvatarDir is where uploaded avatars are written; they are served
+	// back under AvatarURLPrefix.
+	AvatarDir       string
+	AvatarURLPrefix string
+	AvatarMaxBytes  int64
+	// DeletionGrace is how long a deleted account can still be restored.
+	DeletionGrace time.Duration
+}
+
+type AccountService struct {
+	userRepo    *repository.UserRepository
+	authService *AuthService
+	passwords   *PasswordService
+	privacy     *PrivacyService
+	workspaces  *WorkspaceService
+	throttle    *LoginThrottle
+	policy      AccountPolicy
+}
+
+func NewAccountService(userRepo *repository.UserRepository, authService *AuthService, passwords *PasswordService, privacy *PrivacyService, workspaces *WorkspaceService, throttle *LoginThrottle, policy AccountPolicy) *AccountService {
+	return &AccountService{
+		userRepo:    userRepo,
+		authService: authService,
+		passwords:   passwords,
+		privacy:     privacy,
+		workspaces:  workspaces,
+		throttle:    throttle,
+		policy:      policy,
+	}
+}
+
+type UpdateProfileInput struct {
+	Username *string `json:"username" validate:"omitempty,min=3,max=50"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+	Name     *string `json:"name" validate:"omitempty,max=255"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password" validate:"required"`
+	MFACode  string `json:"mfa_code,omitempty"`
+}
+
+func (s *AccountService) UpdateProfile(ctx context.Context, userID int, input UpdateProfileInput) (_ *UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.UpdateProfile")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Username != nil && *input.Username != user.Username {
+		exists, err := s.userRepo.UsernameExists(ctx, *input.Username)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errors.New("username already taken")
+		}
+		user.Username = *input.Username
+	}
+
+	if input.Email != nil && *input.Email != user.Email {
+		exists, err := s.userRepo.EmailExists(ctx, *input.Email)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errors.New("email already registered")
+		}
+		user.Email = *input.Email
+	}
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+
+	err = s.userRepo.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return profileResponse(user), nil
+}
+
+func (s *AccountService) ChangePassword(ctx context.Context, userID int, input ChangePasswordInput, ip string) (err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ChangePassword")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Goes through the login throttle, so a stolen session cannot be used
+	// to guess the current password
+	if _, _, err := s.authService.checkCredentials(ctx, user.Username, input.CurrentPassword, ip); err != nil {
+		if err.Error() == "invalid credentials" {
+			return errors.New("invalid current password")
+		}
+		return err
+	}
+
+	if err := s.passwords.Validate(user.Username, input.NewPassword); err != nil {
+		return err
+	}
+
+	hash, err := s.passwords.Hash(input.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePasswordHash(ctx, user.ID, hash); err != nil {
+		return err
+	}
+
+	if user.PasswordResetRequired {
+		return s.userRepo.ClearPasswordReset(ctx, user.ID)
+	}
+	return nil
+}
+
+// UpdateAvatar stores a new profile picture and removes the previous one.
+func (s *AccountService) UpdateAvatar(ctx context.Context, userID int, upload io.Reader) (_ *UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.UpdateAvatar")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(upload, s.policy.AvatarMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.policy.AvatarMaxBytes {
+		return nil, errors.New("avatar too large")
+	}
+
+	// Trust the content, not the client-supplied file name or type
+	ext, ok := avatarTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, errors.New("unsupported avatar format")
+	}
+
+	suffix, err := randomString(8)
+	if err != nil {
+		return nil, err
+	}
+	filename := strconv.Itoa(user.ID) + "-" + suffix + ext
+
+	if err := os.MkdirAll(s.policy.AvatarDir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(s.policy.AvatarDir, filename), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	previous := user.AvatarURL
+	user.AvatarURL = path.Join(s.policy.AvatarURLPrefix, filename)
+	if err := s.userRepo.UpdateAvatar(ctx, user.ID, user.AvatarURL); err != nil {
+		os.Remove(filepath.Join(s.policy.AvatarDir, filename))
+		return nil, err
+	}
+
+	s.removeAvatar(previous)
+	return profileResponse(user), nil
+}
+
+// RequestDeletion re-authenticates the user and schedules the account for
+// deletion once the grace period ends. The last owner of a shared workspace
+// must hand it over first.
+func (s *AccountService) RequestDeletion(ctx context.Context, userID int, input DeleteAccountInput, ip string) (_ *UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.RequestDeletion")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authService.AuthenticateUser(ctx, user.Username, input.Password, input.MFACode, ip); err != nil {
+		return nil, err
+	}
+
+	if err := s.workspaces.KeepOwners(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	deleteAt := time.Now().Add(s.policy.DeletionGrace)
+	if err := s.userRepo.ScheduleDeletion(ctx, user.ID, &deleteAt); err != nil {
+		return nil, err
+	}
+
+	user.DeletionScheduledAt = &deleteAt
+	return profileResponse(user), nil
+}
+
+func (s *AccountService) CancelDeletion(ctx context.Context, userID int) (_ *UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.CancelDeletion")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletionScheduledAt == nil {
+		return nil, errors.New("account is not scheduled for deletion")
+	}
+
+	if err := s.userRepo.ScheduleDeletion(ctx, user.ID, nil); err != nil {
+		return nil, err
+	}
+
+	user.DeletionScheduledAt = nil
+	return profileResponse(user), nil
+}
+
+// PurgeDeletedAccounts erases every account whose grace period has ended.
+// An account that became the last owner of a shared workspace since it was
+// scheduled is skipped and stays scheduled until ownership is handed over.
+func (s *AccountService) PurgeDeletedAccounts(ctx context.Context) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "AccountService.PurgeDeletedAccounts")
+	defer func() { tracing.End(span, err) }()
+
+	users, err := s.userRepo.GetDueForDeletion(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		if err := s.privacy.EraseUser(ctx, user.ID); err != nil {
+			if err.Error() == "last owner of a shared workspace" {
+				logging.FromContext(ctx).Warn("account purge skipped", "user_id", user.ID, "error", err)
+				continue
+			}
+			return purged, err
+		}
+		s.removeAvatar(user.AvatarURL)
+		purged++
+	}
+
+	return purged, nil
+}
+
+// RunPurge erases due accounts, expired data exports and expired login
+// attempt counters every interval until ctx is done. A purge in progress is completed before returning,
+// within purgeTimeout.
+func (s *AccountService) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.purge(ctx)
+	}
+}
+
+func (s *AccountService) purge(ctx context.Context) {
+	// Keeps the worker's logger but not its cancellation, so shutting down
+	// does not abort an erasure halfway
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), purgeTimeout)
+	defer cancel()
+
+	logger := logging.FromContext(ctx)
+	purged, err := s.PurgeDeletedAccounts(ctx)
+	if err != nil {
+		logger.Error("account purge failed", "error", err)
+	}
+	if purged > 0 {
+		logger.Info("purged deleted accounts", "count", purged)
+	}
+
+	if err := s.privacy.PurgeExpiredExports(ctx); err != nil {
+		logger.Error("export purge failed", "error", err)
+	}
+
+	deleted, err := s.throttle.Cleanup(ctx)
+	if err != nil {
+		logger.Error("login attempt purge failed", "error", err)
+	}
+	if deleted > 0 {
+		logger.Info("purged expired login attempts", "count", deleted)
+	}
+}
+
+func (s *AccountService) removeAvatar(avatarURL string) {
+	if avatarURL == "" {
+		return
+	}
+	// Only the base name is used, so a stored URL can never point the
+	// delete outside AvatarDir
+	os.Remove(filepath.Join(s.policy.AvatarDir, path.Base(avatarURL)))
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+const (
+	adminDefaultPerPage = 20
+	adminMaxPerPage     = 100
+)
+
+type AdminService struct {
+	userRepo    *repository.UserRepository
+	auditRepo   *repository.AuditRepository
+	authService *AuthService
+	passwords   *PasswordService
+}
+
+func NewAdminService(userRepo *repository.UserRepository, auditRepo *repository.AuditRepository, authService *AuthService, passwords *PasswordService) *AdminService {
+	return &AdminService{
+		userRepo:    userRepo,
+		auditRepo:   auditRepo,
+		authService: authService,
+		passwords:   passwords,
+	}
+}
+
+type ListUsersInput struct {
+	Search  string
+	Page    int
+	PerPage int
+}
+
+type AdminUserResponse struct {
+	ID                    int        `json:"id"`
+	Username              string     `json:"username"`
+	Email                 string     `json:"email"`
+	Name                  string     `json:"name"`
+	AvatarURL             string     `json:"avatar_url,omitempty"`
+	Role                  string     `json:"role"`
+	Disabled              bool       `json:"disabled"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	DeletionScheduledAt   *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+type UserListResponse struct {
+	Users   []*AdminUserResponse `json:"users"`
+	Total   int                  `json:"total"`
+	Page    int                  `json:"page"`
+	PerPage int                  `json:"per_page"`
+}
+
+type UpdateUserInput struct {
+	Role     *string `json:"role" validate:"omitempty,oneof=admin user"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type PasswordResetResponse struct {
+	TemporaryPassword string `json:"temporary_password"`
+}
+
+type ImpersonateInput struct {
+	// AllowWrites grants tasks:write; impersonation is read-only otherwise
+	AllowWrites bool `json:"allow_writes"`
+}
+
+type ImpersonationResponse struct {
+	Token     string             `json:"token"`
+	Scopes    []string           `json:"scopes"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	User      *AdminUserResponse `json:"user"`
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, input ListUsersInput) (_ *UserListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	if input.Page < 1 {
+		input.Page = 1
+	}
+	if input.PerPage < 1 {
+		input.PerPage = adminDefaultPerPage
+	}
+	if input.PerPage > adminMaxPerPage {
+		input.PerPage = adminMaxPerPage
+	}
+
+	users, total, err := s.userRepo.List(ctx, input.Search, input.PerPage, (input.Page-1)*input.PerPage)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*AdminUserResponse, len(users))
+	for i, user := range users {
+		responses[i] = s.userToResponse(user)
+	}
+
+	return &UserListResponse{
+		Users:   responses,
+		Total:   total,
+		Page:    input.Page,
+		PerPage: input.PerPage,
+	}, nil
+}
+
+func (s *AdminService) UpdateUser(ctx context.Context, adminID, userID int, input UpdateUserInput, ip string) (_ *AdminUserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.UpdateUser")
+	defer func() { tracing.End(span, err) }()
+
+	// Keeps at least one admin able to sign in
+	if adminID == userID {
+		return nil, errors.New("cannot change your own role or status")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Role != nil {
+		user.Role = *input.Role
+	}
+	if input.Disabled != nil {
+		user.Disabled = *input.Disabled
+	}
+
+	err = s.userRepo.UpdateRoleAndStatus(ctx, user.ID, user.Role, user.Disabled)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, adminID, models.AuditActionAdminUserUpdated, ip, map[string]string{
+		"target_user_id": strconv.Itoa(user.ID),
+		"role":           user.Role,
+		"disabled":       strconv.FormatBool(user.Disabled),
+	})
+
+	return s.userToResponse(user), nil
+}
+
+// ForcePasswordReset sets a random temporary password, ends the user's
+// sessions and makes them choose a new password on their next login. The
+// temporary password is only returned here.
+func (s *AdminService) ForcePasswordReset(ctx context.Context, adminID, userID int, ip string) (_ *PasswordResetResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.ForcePasswordReset")
+	defer func() { tracing.End(span, err) }()
+
+	password, err := randomString(12)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.ForcePasswordReset(ctx, userID, hash); err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, adminID, models.AuditActionAdminPasswordReset, ip, map[string]string{
+		"target_user_id": strconv.Itoa(userID),
+	})
+
+	return &PasswordResetResponse{TemporaryPassword: password}, nil
+}
+
+// ForceLogout invalidates every session, personal access token and OAuth
+// access token issued to the user so far.
+func (s *AdminService) ForceLogout(ctx context.Context, adminID, userID int, ip string) (err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.ForceLogout")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.userRepo.RevokeSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit(ctx, adminID, models.AuditActionAdminLogout, ip, map[string]string{
+		"target_user_id": strconv.Itoa(userID),
+	})
+
+	return nil
+}
+
+// Impersonate issues a short-lived session acting as userID. Account
+// management is never granted, so the token cannot change credentials,
+// mint other tokens or reach the admin API.
+func (s *AdminService) Impersonate(ctx context.Context, adminID, userID int, input ImpersonateInput, ip string) (_ *ImpersonationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AdminService.Impersonate")
+	defer func() { tracing.End(span, err) }()
+
+	if adminID == userID {
+		return nil, errors.New("cannot impersonate yourself")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role == models.RoleAdmin {
+		return nil, errors.New("cannot impersonate an admin")
+	}
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	scopes := []string{models.ScopeTasksRead}
+	if input.AllowWrites {
+		scopes = append(scopes, models.ScopeTasksWrite)
+	}
+
+	lifetime := s.authService.lifetimes.Impersonation
+	token, err := s.authService.generateImpersonationToken(user.ID, adminID, scopes, lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(ctx, adminID, models.AuditActionAdminImpersonate, ip, map[string]string{
+		"target_user_id": strconv.Itoa(user.ID),
+		"scope":          strings.Join(scopes, " "),
+	})
+
+	return &ImpersonationResponse{
+		Token:     token,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(lifetime),
+		User:      s.userToResponse(user),
+	}, nil
+}
+
+// RecordImpersonatedRequest writes one audit entry per request made with
+// an impersonation token. It satisfies middleware.ImpersonationRecorder.
+func (s *AdminService) RecordImpersonatedRequest(ctx context.Context, actorID, userID int, method, path, ip string, status int) {
+	s.audit(ctx, actorID, models.AuditActionImpersonatedRequest, ip, map[string]string{
+		"impersonated_user_id": strconv.Itoa(userID),
+		"method":               method,
+		"path":                 path,
+		"status":               strconv.Itoa(status),
+	})
+}
+
+// audit records admin actions; a failure to write the trail does not undo
+// the action.
+func (s *AdminService) audit(ctx context.Context, adminID int, action, ip string, metadata map[string]string) {
+	s.auditRepo.Create(ctx, &models.AuditEvent{
+		UserID:    &adminID,
+		Action:    action,
+		IPAddress: ip,
+		Metadata:  metadata,
+	})
+}
+
+func (s *AdminService) userToResponse(user *models.User) *AdminUserResponse {
+	return &AdminUserResponse{
+		ID:                    user.ID,
+		Username:              user.Username,
+		Email:                 user.Email,
+		Name:                  user.Name,
+		AvatarURL:             user.AvatarURL,
+		Role:                  user.Role,
+		Disabled:              user.Disabled,
+		PasswordResetRequired: user.PasswordResetRequired,
+		DeletionScheduledAt:   user.DeletionScheduledAt,
+		CreatedAt:             user.CreatedAt,
+		UpdatedAt:             user.UpdatedAt,
+	}
+}
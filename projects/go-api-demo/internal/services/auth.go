@@ -1,24 +1,50 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/malex1718/go-api-demo/internal/metrics"
 	"github.com/malex1718/go-api-demo/internal/models"
 	"github.com/malex1718/go-api-demo/internal/repository"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/malex1718/go-api-demo/internal/signing"
+	"github.com/malex1718/go-api-demo/internal/tracing"
 )
 
 type AuthService struct {
 	userRepo  *repository.UserRepository
-	jwtSecret []byte
+	mfaRepo   *repository.MFARepository
+	throttle  *LoginThrottle
+	passwords *PasswordService
+	keys      *signing.Keys
+	// tenant is added to every token in multi-tenant deployments so the
+	// tenant can be resolved from the token alone
+	tenant    string
+	lifetimes TokenLifetimes
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtSecret []byte) *AuthService {
+// TokenLifetimes sets how long the tokens the API issues stay valid.
+type TokenLifetimes struct {
+	Access        time.Duration
+	MFAChallenge  time.Duration
+	Impersonation time.Duration
+	OAuthCode     time.Duration
+	OAuthToken    time.Duration
+}
+
+func NewAuthService(userRepo *repository.UserRepository, mfaRepo *repository.MFARepository, throttle *LoginThrottle, passwords *PasswordService, keys *signing.Keys, tenant string, lifetimes TokenLifetimes) *AuthService {
 	return &AuthService{
 		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		mfaRepo:   mfaRepo,
+		throttle:  throttle,
+		passwords: passwords,
+		keys:      keys,
+		tenant:    tenant,
+		lifetimes: lifetimes,
 	}
 }
 
@@ -29,25 +55,37 @@ type RegisterInput struct {
 }
 
 type LoginInput struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Username string   `json:"username" validate:"required"`
+	Password string   `json:"password" validate:"required"`
+	Scopes   []string `json:"scopes,omitempty" validate:"omitempty,dive,oneof=tasks:read tasks:write account:manage"`
 }
 
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token          string `json:"token,omitempty"`
+	MFARequired    bool   `json:"mfa_required,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	// PasswordResetRequired means the token only allows changing the password
+	PasswordResetRequired bool          `json:"password_reset_required,omitempty"`
+	User                  *UserResponse `json:"user,omitempty"`
 }
 
 type UserResponse struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                  int        `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	Name                string     `json:"name,omitempty"`
+	AvatarURL           string     `json:"avatar_url,omitempty"`
+	Role                string     `json:"role,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
 }
 
-func (s *AuthService) Register(input RegisterInput) (*AuthResponse, error) {
+func (s *AuthService) Register(ctx context.Context, input RegisterInput) (_ *AuthResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Register")
+	defer func() { tracing.End(span, err) }()
+
 	// Check if username already exists
-	exists, err := s.userRepo.UsernameExists(input.Username)
+	exists, err := s.userRepo.UsernameExists(ctx, input.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +94,7 @@ func (s *AuthService) Register(input RegisterInput) (*AuthResponse, error) {
 	}
 
 	// Check if email already exists
-	exists, err = s.userRepo.EmailExists(input.Email)
+	exists, err = s.userRepo.EmailExists(ctx, input.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -64,8 +102,13 @@ func (s *AuthService) Register(input RegisterInput) (*AuthResponse, error) {
 		return nil, errors.New("email already registered")
 	}
 
+	// Enforce password policy
+	if err := s.passwords.Validate(input.Username, input.Password); err != nil {
+		return nil, err
+	}
+
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(input.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -74,23 +117,23 @@ func (s *AuthService) Register(input RegisterInput) (*AuthResponse, error) {
 	user := &models.User{
 		Username:     input.Username,
 		Email:        input.Email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
 
-	err = s.userRepo.Create(user)
+	err = s.userRepo.Create(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generate JWT token
-	token, err := s.generateToken(user.ID)
+	token, err := s.generateToken(user.ID, models.DefaultScopes)
 	if err != nil {
 		return nil, err
 	}
 
 	return &AuthResponse{
 		Token: token,
-		User: UserResponse{
+		User: &UserResponse{
 			ID:        user.ID,
 			Username:  user.Username,
 			Email:     user.Email,
@@ -99,28 +142,78 @@ func (s *AuthService) Register(input RegisterInput) (*AuthResponse, error) {
 	}, nil
 }
 
-func (s *AuthService) Login(input LoginInput) (*AuthResponse, error) {
-	// Find user by username
-	user, err := s.userRepo.GetByUsername(input.Username)
+func (s *AuthService) Login(ctx context.Context, input LoginInput, ip string) (_ *AuthResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer func() { tracing.End(span, err) }()
+
+	user, mfaEnabled, err := s.checkCredentials(ctx, input.Username, input.Password, ip)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		recordLogin(err)
+		return nil, err
 	}
 
-	// Check password
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
+	// Callers may ask for a narrower, e.g. read-only, session
+	scopes := models.DefaultScopes
+	if len(input.Scopes) > 0 {
+		scopes = input.Scopes
+	}
+
+	// After an admin reset the session can only be used to set a new password
+	if user.PasswordResetRequired {
+		scopes = []string{models.ScopeAccountManage}
+	}
+
+	// Second factor: startSession hands out a challenge instead of an
+	// access token, and the login counts once the code is verified
+	response, err := s.startSession(user, mfaEnabled, scopes)
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
+	}
+	if !mfaEnabled {
+		recordLogin(nil)
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user.ID)
+	response.PasswordResetRequired = user.PasswordResetRequired
+	return response, nil
+}
+
+// completeExternalLogin signs in a user an identity provider vouched for.
+// The provider does not stand in for the account's own second factor.
+func (s *AuthService) completeExternalLogin(ctx context.Context, user *models.User) (*AuthResponse, error) {
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	_, mfaEnabled, err := s.mfaRepo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, mfaEnabled, models.DefaultScopes)
+}
+
+// startSession finishes a login whose first factor is verified. Accounts
+// with MFA get a challenge for VerifyMFA instead of an access token.
+func (s *AuthService) startSession(user *models.User, mfaEnabled bool, scopes []string) (*AuthResponse, error) {
+	if mfaEnabled {
+		challenge, err := s.generateChallengeToken(user.ID, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return &AuthResponse{
+			MFARequired:    true,
+			ChallengeToken: challenge,
+		}, nil
+	}
+
+	token, err := s.generateToken(user.ID, scopes)
 	if err != nil {
 		return nil, err
 	}
 
 	return &AuthResponse{
 		Token: token,
-		User: UserResponse{
+		User: &UserResponse{
 			ID:        user.ID,
 			Username:  user.Username,
 			Email:     user.Email,
@@ -129,12 +222,145 @@ func (s *AuthService) Login(input LoginInput) (*AuthResponse, error) {
 	}, nil
 }
 
+// AuthenticateUser verifies username, password and, when the account has
+// MFA enabled, the TOTP or recovery code in a single step. It serves
+// flows that cannot do the two-step challenge, like the OAuth consent form.
+func (s *AuthService) AuthenticateUser(ctx context.Context, username, password, mfaCode, ip string) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.AuthenticateUser")
+	defer func() { tracing.End(span, err) }()
+
+	user, mfaEnabled, err := s.checkCredentials(ctx, username, password, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfaEnabled {
+		if mfaCode == "" {
+			return nil, errors.New("mfa code required")
+		}
+		if err := s.checkSecondFactor(ctx, user, mfaCode, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// checkCredentials verifies username and password and reports whether the
+// account still has to pass its second factor. In that case the attempt
+// is released rather than settled, so failures before it keep counting
+// until the second factor is verified.
+func (s *AuthService) checkCredentials(ctx context.Context, username, password, ip string) (*models.User, bool, error) {
+	// Refuse early while the account or client is locked or backing off
+	if err := s.throttle.Check(ctx, username, ip); err != nil {
+		return nil, false, err
+	}
+
+	// Find user by username
+	user, err := s.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		s.passwords.VerifyDummy(password)
+		if err := s.throttle.RecordFailure(ctx, username, ip, nil); err != nil {
+			return nil, false, err
+		}
+		return nil, false, errors.New("invalid credentials")
+	}
+
+	// Check password
+	match, needsRehash, err := s.passwords.Verify(password, user.PasswordHash)
+	if err != nil {
+		return nil, false, err
+	}
+	if !match {
+		if err := s.throttle.RecordFailure(ctx, username, ip, &user.ID); err != nil {
+			return nil, false, err
+		}
+		return nil, false, errors.New("invalid credentials")
+	}
+
+	// Only reported once the password is proven, so it does not reveal
+	// which usernames exist
+	if user.Disabled {
+		if err := s.throttle.Release(ctx, username, ip); err != nil {
+			return nil, false, err
+		}
+		return nil, false, errors.New("account disabled")
+	}
+
+	// Upgrade hashes made with an older algorithm or parameters. Best
+	// effort: the old hash keeps working if this fails.
+	if needsRehash {
+		if hash, err := s.passwords.Hash(password); err == nil {
+			s.userRepo.UpdatePasswordHash(ctx, user.ID, hash)
+		}
+	}
+
+	_, mfaEnabled, err := s.mfaRepo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if mfaEnabled {
+		if err := s.throttle.Release(ctx, username, ip); err != nil {
+			return nil, false, err
+		}
+		return user, true, nil
+	}
+
+	if err := s.throttle.RecordSuccess(ctx, username, ip); err != nil {
+		return nil, false, err
+	}
+
+	return user, false, nil
+}
+
+// recordLogin counts the outcome of a password or second-factor check.
+// With MFA enabled a login only counts as a success once the code is
+// verified.
+func recordLogin(err error) {
+	if err == nil {
+		metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
+		return
+	}
+
+	switch err.Error() {
+	case "too many failed attempts":
+		metrics.Logins.WithLabelValues(metrics.LoginLockout).Inc()
+	case "invalid credentials", "account disabled", "invalid mfa code":
+		metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+	}
+}
+
+// CheckSession is called on every authenticated request. It rejects
+// disabled accounts and tokens issued before an admin forced a logout.
+// It returns the user's role.
+func (s *AuthService) CheckSession(ctx context.Context, userID int, issuedAt time.Time) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.CheckSession")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if user.Disabled {
+		return "", errors.New("account disabled")
+	}
+
+	// iat has second precision
+	if !issuedAt.IsZero() && user.SessionsRevokedAt != nil &&
+		issuedAt.Before(user.SessionsRevokedAt.Truncate(time.Second)) {
+		return "", errors.New("session revoked")
+	}
+
+	return user.Role, nil
+}
+
 func (s *AuthService) ValidateToken(tokenString string) (int, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
 		}
-		return s.jwtSecret, nil
+		return s.keys.JWT(), nil
 	})
 
 	if err != nil {
@@ -150,6 +376,11 @@ func (s *AuthService) ValidateToken(tokenString string) (int, error) {
 		return 0, errors.New("invalid token claims")
 	}
 
+	// MFA challenge tokens are not access tokens
+	if purpose, _ := claims["purpose"].(string); purpose != "" {
+		return 0, errors.New("invalid token")
+	}
+
 	userID, ok := claims["user_id"].(float64)
 	if !ok {
 		return 0, errors.New("invalid user_id in token")
@@ -158,27 +389,62 @@ func (s *AuthService) ValidateToken(tokenString string) (int, error) {
 	return int(userID), nil
 }
 
-func (s *AuthService) GetUserByID(id int) (*UserResponse, error) {
-	user, err := s.userRepo.GetByID(id)
+func (s *AuthService) GetUserByID(ctx context.Context, id int) (_ *UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GetUserByID")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
+	return profileResponse(user), nil
+}
+
+// profileResponse is the full view of an account, returned to its owner.
+func profileResponse(user *models.User) *UserResponse {
 	return &UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-	}, nil
+		ID:                  user.ID,
+		Username:            user.Username,
+		Email:               user.Email,
+		Name:                user.Name,
+		AvatarURL:           user.AvatarURL,
+		Role:                user.Role,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		CreatedAt:           user.CreatedAt,
+	}
+}
+
+// generateImpersonationToken issues a session for userID on behalf of an
+// admin. The admin is recorded in the RFC 8693 "act" claim.
+func (s *AuthService) generateImpersonationToken(userID, actorID int, scopes []string, lifetime time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"scope":   strings.Join(scopes, " "),
+		"act":     map[string]string{"sub": strconv.Itoa(actorID)},
+		"exp":     time.Now().Add(lifetime).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	return s.sign(claims)
 }
 
-func (s *AuthService) generateToken(userID int) (string, error) {
+func (s *AuthService) generateToken(userID int, scopes []string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+		"scope":   strings.Join(scopes, " "),
+		"exp":     time.Now().Add(s.lifetimes.Access).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
+	return s.sign(claims)
+}
+
+func (s *AuthService) sign(claims jwt.MapClaims) (string, error) {
+	if s.tenant != "" {
+		claims[models.TenantClaim] = s.tenant
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.jwtSecret)
+	return token.SignedString(s.keys.Current())
 }
\ No newline at end of file
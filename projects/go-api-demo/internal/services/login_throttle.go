@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+)
+
+type LockoutPolicy struct {
+	MaxFailures     int
+	IPMaxFailures   int
+	BackoffBase     time.Duration
+	MaxBackoff      time.Duration
+	LockoutDuration time.Duration
+	// ChallengeLifetime is how long an MFA challenge can be answered; its
+	// attempts are kept at least that long.
+	ChallengeLifetime time.Duration
+}
+
+// LoginThrottle tracks failed logins per account and per client IP,
+// slowing attackers down exponentially and locking them out after
+// too many failures.
+type LoginThrottle struct {
+	attemptRepo *repository.LoginAttemptRepository
+	auditRepo   *repository.AuditRepository
+	policy      LockoutPolicy
+}
+
+func NewLoginThrottle(attemptRepo *repository.LoginAttemptRepository, auditRepo *repository.AuditRepository, policy LockoutPolicy) *LoginThrottle {
+	return &LoginThrottle{
+		attemptRepo: attemptRepo,
+		auditRepo:   auditRepo,
+		policy:      policy,
+	}
+}
+
+// Check reserves an attempt on the account and the client IP, refusing it
+// while either is locked or backing off. The attempt counts as a failure
+// until it is settled with RecordFailure, RecordSuccess or Release, so
+// parallel guesses are throttled like sequential ones.
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	windowStart := now.Add(-t.policy.LockoutDuration)
+
+	ok, err := t.attemptRepo.Acquire(ctx, accountKey(username), now, windowStart, t.policy.MaxFailures, t.policy.BackoffBase, t.policy.MaxBackoff)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("too many failed attempts")
+	}
+
+	ok, err = t.attemptRepo.Acquire(ctx, ipKey(ip), now, windowStart, t.policy.IPMaxFailures, t.policy.BackoffBase, t.policy.MaxBackoff)
+	if err == nil && !ok {
+		err = errors.New("too many failed attempts")
+	}
+	if err != nil {
+		// The attempt is not made, so it does not count on the account
+		// either; best effort, as it only delays the account's next try
+		t.attemptRepo.Refund(ctx, accountKey(username))
+		return err
+	}
+
+	return nil
+}
+
+// RecordFailure settles a failed attempt, already counted by Check, and
+// locks the account or IP that reached its limit.
+func (t *LoginThrottle) RecordFailure(ctx context.Context, username, ip string, userID *int) error {
+	now := time.Now()
+	lockedUntil := now.Add(t.policy.LockoutDuration)
+
+	counters := []struct {
+		key         string
+		keyType     string
+		maxFailures int
+		userID      *int
+	}{
+		{accountKey(username), "account", t.policy.MaxFailures, userID},
+		{ipKey(ip), "ip", t.policy.IPMaxFailures, nil},
+	}
+
+	for _, c := range counters {
+		failures, locked, err := t.attemptRepo.Lock(ctx, c.key, c.maxFailures, now, lockedUntil)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			continue
+		}
+
+		err = t.auditRepo.Create(ctx, &models.AuditEvent{
+			UserID:    c.userID,
+			Action:    models.AuditActionLoginLockout,
+			IPAddress: ip,
+			Metadata: map[string]string{
+				"scope":        c.keyType,
+				"username":     username,
+				"failures":     strconv.Itoa(failures),
+				"locked_until": lockedUntil.UTC().Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the account counter and takes back the attempt
+// counted on the IP. The rest of the IP counter is left to expire on its
+// own so an attacker cannot reset it with their own account.
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, username, ip string) error {
+	if err := t.attemptRepo.Reset(ctx, accountKey(username)); err != nil {
+		return err
+	}
+	return t.attemptRepo.Refund(ctx, ipKey(ip))
+}
+
+// Release takes back an attempt that neither failed nor completed a
+// login, such as a correct password for a disabled account.
+func (t *LoginThrottle) Release(ctx context.Context, username, ip string) error {
+	if err := t.attemptRepo.Refund(ctx, accountKey(username)); err != nil {
+		return err
+	}
+	return t.attemptRepo.Refund(ctx, ipKey(ip))
+}
+
+// Cleanup deletes the counters that have expired: their failures are out of
+// the lockout window, any lockout has ended and, for MFA challenges, the
+// challenge itself can no longer be answered. It returns how many were
+// deleted.
+func (t *LoginThrottle) Cleanup(ctx context.Context) (int64, error) {
+	now := time.Now()
+	retention := max(t.policy.LockoutDuration, t.policy.ChallengeLifetime)
+	return t.attemptRepo.DeleteExpired(ctx, now.Add(-retention), now)
+}
+
+// mfaChallengeMaxAttempts is how many codes can be tried against one MFA
+// challenge before the user has to log in again.
+const mfaChallengeMaxAttempts = 5
+
+// CheckChallenge counts an attempt against an MFA challenge, refusing it
+// once the challenge is spent or out of attempts.
+func (t *LoginThrottle) CheckChallenge(ctx context.Context, id string) error {
+	// A zero window start keeps the attempts for the challenge's lifetime
+	ok, err := t.attemptRepo.Acquire(ctx, challengeKey(id), time.Now(), time.Time{}, mfaChallengeMaxAttempts, 0, 0)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid or expired challenge")
+	}
+	return nil
+}
+
+// ConsumeChallenge spends an MFA challenge until it expires. Only the first
+// caller succeeds, so a challenge completes at most one login.
+func (t *LoginThrottle) ConsumeChallenge(ctx context.Context, id string, expiresAt time.Time) error {
+	_, locked, err := t.attemptRepo.Lock(ctx, challengeKey(id), 0, time.Now(), expiresAt)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return errors.New("invalid or expired challenge")
+	}
+	return nil
+}
+
+func accountKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func challengeKey(id string) string {
+	return "mfa:" + id
+}
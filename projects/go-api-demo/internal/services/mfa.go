@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer           = "go-api-demo"
+	mfaChallengePurpose  = "mfa"
+	recoveryCodeCount    = 10
+	recoveryCodeGroupLen = 5
+)
+
+type TOTPEnrollment struct {
+	Secret    string `json:"secret"`
+	URI       string `json:"otpauth_uri"`
+	QRCodePNG []byte `json:"qr_code_png"`
+}
+
+type ConfirmTOTPInput struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type VerifyMFAInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (s *AuthService) EnrollTOTP(ctx context.Context, userID int) (_ *TOTPEnrollment, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.EnrollTOTP")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, enabled, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, errors.New("mfa already enabled")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored but not enabled until the first code is confirmed
+	if err := s.mfaRepo.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	uri := totpURI(totpIssuer, user.Username, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:    secret,
+		URI:       uri,
+		QRCodePNG: png,
+	}, nil
+}
+
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID int, input ConfirmTOTPInput) (_ *RecoveryCodesResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ConfirmTOTP")
+	defer func() { tracing.End(span, err) }()
+
+	secret, enabled, err := s.mfaRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, errors.New("mfa already enabled")
+	}
+	if secret == "" {
+		return nil, errors.New("mfa enrollment not started")
+	}
+
+	counter, ok := validateTOTP(secret, input.Code, time.Now())
+	if !ok {
+		return nil, errors.New("invalid mfa code")
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = string(hash)
+	}
+
+	if err := s.mfaRepo.EnableTOTP(ctx, userID, counter, hashes); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *AuthService) VerifyMFA(ctx context.Context, input VerifyMFAInput, ip string) (_ *AuthResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.VerifyMFA")
+	defer func() { tracing.End(span, err) }()
+
+	challenge, err := s.parseChallengeToken(input.ChallengeToken)
+	if err != nil {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	// Each challenge allows a few guesses and is spent by the first
+	// correct one
+	if err := s.throttle.CheckChallenge(ctx, challenge.id); err != nil {
+		return nil, err
+	}
+
+	_, enabled, err := s.mfaRepo.GetTOTP(ctx, challenge.userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSecondFactor(ctx, user, input.Code, ip); err != nil {
+		recordLogin(err)
+		return nil, err
+	}
+
+	if err := s.throttle.ConsumeChallenge(ctx, challenge.id, challenge.expiresAt); err != nil {
+		return nil, err
+	}
+
+	token, err := s.generateToken(user.ID, challenge.scopes)
+	if err != nil {
+		return nil, err
+	}
+	recordLogin(nil)
+
+	return &AuthResponse{
+		Token: token,
+		User: &UserResponse{
+			ID:        user.ID,
+			Username:  user.Username,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+		},
+	}, nil
+}
+
+// checkSecondFactor verifies a TOTP or recovery code for user. Wrong codes
+// count against the same account and IP limits as wrong passwords.
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *models.User, code, ip string) error {
+	if err := s.throttle.Check(ctx, user.Username, ip); err != nil {
+		return err
+	}
+
+	ok, err := s.verifySecondFactor(ctx, user.ID, code)
+	if err != nil {
+		s.throttle.Release(ctx, user.Username, ip)
+		return err
+	}
+	if !ok {
+		if err := s.throttle.RecordFailure(ctx, user.Username, ip, &user.ID); err != nil {
+			return err
+		}
+		return errors.New("invalid mfa code")
+	}
+
+	return s.throttle.RecordSuccess(ctx, user.Username, ip)
+}
+
+// verifySecondFactor accepts a TOTP code that was not used before or
+// consumes an unused recovery code. Input shaped like neither is refused
+// without touching the recovery code hashes.
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID int, code string) (bool, error) {
+	if isTOTPCode(code) {
+		secret, _, err := s.mfaRepo.GetTOTP(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+
+		counter, ok := validateTOTP(secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		// A code stays valid for a few periods; only its first use counts
+		return s.mfaRepo.UseTOTPCounter(ctx, userID, counter)
+	}
+
+	if isRecoveryCode(code) {
+		return s.useRecoveryCode(ctx, userID, code)
+	}
+
+	return false, nil
+}
+
+func (s *AuthService) useRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+
+	codes, err := s.mfaRepo.GetUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rc := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(rc.CodeHash), []byte(code)) != nil {
+			continue
+		}
+		if err := s.mfaRepo.MarkRecoveryCodeUsed(ctx, rc.ID); err != nil {
+			if err.Error() == "recovery code already used" {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// mfaChallenge is a parsed challenge token. id names the challenge so
+// its attempts can be counted and it can be spent once.
+type mfaChallenge struct {
+	id        string
+	userID    int
+	scopes    []string
+	expiresAt time.Time
+}
+
+func (s *AuthService) generateChallengeToken(userID int, scopes []string) (string, error) {
+	id, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"jti":     id,
+		"user_id": userID,
+		"purpose": mfaChallengePurpose,
+		"scope":   strings.Join(scopes, " "),
+		"exp":     time.Now().Add(s.lifetimes.MFAChallenge).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	return s.sign(claims)
+}
+
+func (s *AuthService) parseChallengeToken(tokenString string) (*mfaChallenge, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return s.keys.JWT(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if purpose, _ := claims["purpose"].(string); purpose != mfaChallengePurpose {
+		return nil, errors.New("invalid token")
+	}
+
+	id, _ := claims["jti"].(string)
+	if id == "" {
+		return nil, errors.New("invalid token")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errors.New("invalid user_id in token")
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, errors.New("invalid token")
+	}
+
+	scope, _ := claims["scope"].(string)
+	return &mfaChallenge{
+		id:        id,
+		userID:    int(userID),
+		scopes:    strings.Fields(scope),
+		expiresAt: exp.Time,
+	}, nil
+}
+
+func generateRecoveryCode() (string, error) {
+	// 48 random bits rendered as two groups of base32 characters
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32NoPadding.EncodeToString(buf))
+	return code[:recoveryCodeGroupLen] + "-" + code[recoveryCodeGroupLen:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) == recoveryCodeGroupLen*2 && !strings.Contains(code, "-") {
+		code = code[:recoveryCodeGroupLen] + "-" + code[recoveryCodeGroupLen:]
+	}
+	return code
+}
+
+// isRecoveryCode reports whether code has the shape generateRecoveryCode
+// produces, with or without the dash.
+func isRecoveryCode(code string) bool {
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeGroupLen*2+1 || code[recoveryCodeGroupLen] != '-' {
+		return false
+	}
+	for i, c := range code {
+		if i == recoveryCodeGroupLen {
+			continue
+		}
+		if !(c >= 'a' && c <= 'z' || c >= '2' && c <= '7') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+// OAuthTokenPrefix marks access tokens issued to OAuth clients.
+const OAuthTokenPrefix = "gad_oat_"
+
+const oauthClientIDPrefix = "gad_client_"
+
+// Errors returned by the token, introspection and revocation endpoints use
+// the RFC 6749 section 5.2 error codes as their message.
+var (
+	errInvalidClient      = errors.New("invalid_client")
+	errInvalidGrant       = errors.New("invalid_grant")
+	errInvalidRequest     = errors.New("invalid_request")
+	errInvalidScope       = errors.New("invalid_scope")
+	errUnauthorizedClient = errors.New("unauthorized_client")
+)
+
+type OAuthService struct {
+	oauthRepo *repository.OAuthRepository
+	userRepo  *repository.UserRepository
+	lifetimes TokenLifetimes
+}
+
+func NewOAuthService(oauthRepo *repository.OAuthRepository, userRepo *repository.UserRepository, lifetimes TokenLifetimes) *OAuthService {
+	return &OAuthService{
+		oauthRepo: oauthRepo,
+		userRepo:  userRepo,
+		lifetimes: lifetimes,
+	}
+}
+
+type RegisterClientInput struct {
+	Name         string   `json:"name" validate:"required,min=1,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=tasks:read tasks:write"`
+	Confidential bool     `json:"confidential"`
+}
+
+type ClientResponse struct {
+	ID           int       `json:"id"`
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RegisteredClientResponse is the only place a client secret is returned.
+type RegisteredClientResponse struct {
+	ClientResponse
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+type ConsentScope struct {
+	Name        string
+	Description string
+}
+
+type ConsentDetails struct {
+	ClientName string
+	Scopes     []ConsentScope
+}
+
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// IntrospectionResponse follows RFC 7662 section 2.2; inactive tokens
+// only carry "active": false.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+}
+
+func (s *OAuthService) RegisterClient(ctx context.Context, ownerID int, input RegisterClientInput) (_ *RegisteredClientResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.RegisterClient")
+	defer func() { tracing.End(span, err) }()
+
+	// Public clients can only use the authorization-code grant
+	if !input.Confidential && len(input.RedirectURIs) == 0 {
+		return nil, errors.New("public clients need at least one redirect URI")
+	}
+
+	suffix, err := randomString(12)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     oauthClientIDPrefix + suffix,
+		OwnerID:      ownerID,
+		Name:         input.Name,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       input.Scopes,
+		Confidential: input.Confidential,
+	}
+
+	var secret string
+	if input.Confidential {
+		secret, err = randomString(32)
+		if err != nil {
+			return nil, err
+		}
+		client.ClientSecretHash = hashToken(secret)
+	}
+
+	err = s.oauthRepo.CreateClient(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegisteredClientResponse{
+		ClientResponse: *s.clientToResponse(client),
+		ClientSecret:   secret,
+	}, nil
+}
+
+func (s *OAuthService) GetUserClients(ctx context.Context, ownerID int) (_ []*ClientResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.GetUserClients")
+	defer func() { tracing.End(span, err) }()
+
+	clients, err := s.oauthRepo.GetClientsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*ClientResponse, len(clients))
+	for i, client := range clients {
+		responses[i] = s.clientToResponse(client)
+	}
+
+	return responses, nil
+}
+
+func (s *OAuthService) DeleteClient(ctx context.Context, id, ownerID int) (err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.DeleteClient")
+	defer func() { tracing.End(span, err) }()
+
+	return s.oauthRepo.DeleteClient(ctx, id, ownerID)
+}
+
+// ValidateAuthorizationRequest checks an authorize request before showing
+// the consent screen. "invalid_client" and "invalid_redirect_uri" must be
+// shown to the user; every other error is sent back to the client's
+// redirect URI.
+func (s *OAuthService) ValidateAuthorizationRequest(ctx context.Context, req AuthorizationRequest) (_ *ConsentDetails, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.ValidateAuthorizationRequest")
+	defer func() { tracing.End(span, err) }()
+
+	client, scopes, err := s.validateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &ConsentDetails{ClientName: client.Name}
+	for _, scope := range scopes {
+		details.Scopes = append(details.Scopes, ConsentScope{
+			Name:        scope,
+			Description: models.ScopeDescriptions[scope],
+		})
+	}
+
+	return details, nil
+}
+
+// Approve issues an authorization code for userID and returns the URL to
+// send the browser back to.
+func (s *OAuthService) Approve(ctx context.Context, req AuthorizationRequest, userID int) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.Approve")
+	defer func() { tracing.End(span, err) }()
+
+	_, scopes, err := s.validateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.oauthRepo.CreateAuthorizationCode(ctx, &models.OAuthAuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.lifetimes.OAuthCode),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return redirectWithParams(req.RedirectURI, url.Values{"code": {code}, "state": {req.State}}), nil
+}
+
+// ErrorRedirect builds the redirect for a denied or failed authorization.
+func (s *OAuthService) ErrorRedirect(req AuthorizationRequest, errCode string) string {
+	return redirectWithParams(req.RedirectURI, url.Values{"error": {errCode}, "state": {req.State}})
+}
+
+func (s *OAuthService) ExchangeAuthorizationCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (_ *OAuthTokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.ExchangeAuthorizationCode")
+	defer func() { tracing.End(span, err) }()
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if code == "" || codeVerifier == "" {
+		return nil, errInvalidRequest
+	}
+
+	authCode, err := s.oauthRepo.ConsumeAuthorizationCode(ctx, hashToken(code))
+	if err != nil {
+		if err.Error() == "authorization code not found" {
+			return nil, errInvalidGrant
+		}
+		return nil, err
+	}
+
+	if authCode.ClientID != client.ClientID ||
+		authCode.RedirectURI != redirectURI ||
+		time.Now().After(authCode.ExpiresAt) {
+		return nil, errInvalidGrant
+	}
+
+	// PKCE, S256 only (RFC 7636 section 4.6)
+	sum := sha256.Sum256([]byte(codeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(authCode.CodeChallenge)) != 1 {
+		return nil, errInvalidGrant
+	}
+
+	return s.issueAccessToken(ctx, client.ClientID, &authCode.UserID, authCode.Scopes)
+}
+
+func (s *OAuthService) ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (_ *OAuthTokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.ClientCredentials")
+	defer func() { tracing.End(span, err) }()
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.Confidential {
+		return nil, errUnauthorizedClient
+	}
+
+	scopes, err := narrowScopes(scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueAccessToken(ctx, client.ClientID, nil, scopes)
+}
+
+func (s *OAuthService) Introspect(ctx context.Context, clientID, clientSecret, plaintext string) (_ *IntrospectionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.Introspect")
+	defer func() { tracing.End(span, err) }()
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only confidential clients act as resource servers
+	if !client.Confidential {
+		return nil, errUnauthorizedClient
+	}
+
+	token, err := s.activeToken(ctx, plaintext)
+	if err != nil {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	response := &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  token.ClientID,
+		TokenType: "Bearer",
+		Exp:       token.ExpiresAt.Unix(),
+		Iat:       token.CreatedAt.Unix(),
+	}
+
+	if token.UserID != nil {
+		response.Sub = strconv.Itoa(*token.UserID)
+		if user, err := s.userRepo.GetByID(ctx, *token.UserID); err == nil {
+			response.Username = user.Username
+		}
+	}
+
+	return response, nil
+}
+
+// Revoke invalidates a token issued to the calling client. Unknown tokens
+// are not an error (RFC 7009 section 2.2).
+func (s *OAuthService) Revoke(ctx context.Context, clientID, clientSecret, plaintext string) (err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.Revoke")
+	defer func() { tracing.End(span, err) }()
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	return s.oauthRepo.RevokeAccessToken(ctx, hashToken(plaintext), client.ClientID)
+}
+
+// AuthenticateToken resolves an OAuth access token to the user it acts
+// for, its scopes and its creation time. It satisfies
+// middleware.TokenAuthenticator.
+func (s *OAuthService) AuthenticateToken(ctx context.Context, plaintext string) (_ int, _ []string, _ time.Time, err error) {
+	ctx, span := tracing.Start(ctx, "OAuthService.AuthenticateToken")
+	defer func() { tracing.End(span, err) }()
+
+	if !strings.HasPrefix(plaintext, OAuthTokenPrefix) {
+		return 0, nil, time.Time{}, errors.New("invalid token")
+	}
+
+	token, err := s.activeToken(ctx, plaintext)
+	if err != nil {
+		return 0, nil, time.Time{}, err
+	}
+
+	// Client-credentials tokens are not bound to a user, so they cannot
+	// reach the per-user task routes
+	if token.UserID == nil {
+		return 0, nil, time.Time{}, errors.New("token has no user")
+	}
+
+	return *token.UserID, token.Scopes, token.CreatedAt, nil
+}
+
+func (s *OAuthService) activeToken(ctx context.Context, plaintext string) (*models.OAuthAccessToken, error) {
+	token, err := s.oauthRepo.GetAccessTokenByHash(ctx, hashToken(plaintext))
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errors.New("invalid token")
+	}
+
+	return token, nil
+}
+
+func (s *OAuthService) issueAccessToken(ctx context.Context, clientID string, userID *int, scopes []string) (*OAuthTokenResponse, error) {
+	raw, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := OAuthTokenPrefix + raw
+
+	err = s.oauthRepo.CreateAccessToken(ctx, &models.OAuthAccessToken{
+		TokenHash: hashToken(plaintext),
+		ClientID:  clientID,
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(s.lifetimes.OAuthToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken: plaintext,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.lifetimes.OAuthToken.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	client, err := s.oauthRepo.GetClientByClientID(ctx, clientID)
+	if err != nil {
+		if err.Error() == "client not found" {
+			return nil, errInvalidClient
+		}
+		return nil, err
+	}
+
+	if client.Confidential {
+		given := hashToken(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(given), []byte(client.ClientSecretHash)) != 1 {
+			return nil, errInvalidClient
+		}
+	}
+
+	return client, nil
+}
+
+func (s *OAuthService) validateAuthorizationRequest(ctx context.Context, req AuthorizationRequest) (*models.OAuthClient, []string, error) {
+	client, err := s.oauthRepo.GetClientByClientID(ctx, req.ClientID)
+	if err != nil {
+		if err.Error() == "client not found" {
+			return nil, nil, errInvalidClient
+		}
+		return nil, nil, err
+	}
+
+	// Exact match only; never redirect to an unregistered URI
+	registered := false
+	for _, uri := range client.RedirectURIs {
+		if uri == req.RedirectURI {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		return nil, nil, errors.New("invalid_redirect_uri")
+	}
+
+	if req.ResponseType != "code" {
+		return nil, nil, errors.New("unsupported_response_type")
+	}
+
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return nil, nil, errInvalidRequest
+	}
+
+	scopes, err := narrowScopes(req.Scope, client.Scopes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, scopes, nil
+}
+
+func (s *OAuthService) clientToResponse(client *models.OAuthClient) *ClientResponse {
+	return &ClientResponse{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Scopes:       client.Scopes,
+		Confidential: client.Confidential,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// narrowScopes returns the requested space-separated scopes, or every
+// allowed scope when none are requested.
+func narrowScopes(requested string, allowed []string) ([]string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return allowed, nil
+	}
+
+	permitted := make(map[string]bool, len(allowed))
+	for _, scope := range allowed {
+		permitted[scope] = true
+	}
+
+	scopes := strings.Fields(requested)
+	for _, scope := range scopes {
+		if !permitted[scope] {
+			return nil, errInvalidScope
+		}
+	}
+
+	return scopes, nil
+}
+
+func redirectWithParams(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
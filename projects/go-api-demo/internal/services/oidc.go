@@ -0,0 +1,461 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/signing"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStatePurpose  = "oidc_state"
+	oidcStateLifetime = 10 * time.Minute
+	oidcHTTPTimeout   = 10 * time.Second
+)
+
+var usernameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// oidcProvider defers discovery until first use, so the API can boot while
+// an identity provider is unreachable.
+type oidcProvider struct {
+	config OIDCProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcUserStore, oidcIdentityStore and oidcSessions are the parts of the
+// repositories and AuthService the OIDC flow uses.
+type oidcUserStore interface {
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	Create(ctx context.Context, user *models.User) error
+}
+
+type oidcIdentityStore interface {
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	Create(ctx context.Context, identity *models.UserIdentity) error
+}
+
+type oidcSessions interface {
+	completeExternalLogin(ctx context.Context, user *models.User) (*AuthResponse, error)
+}
+
+type OIDCService struct {
+	providers    map[string]*oidcProvider
+	userRepo     oidcUserStore
+	identityRepo oidcIdentityStore
+	authService  oidcSessions
+	passwords    *PasswordService
+	httpClient   *http.Client
+	stateKeys    *signing.Keys
+}
+
+// NewOIDCService wires the configured providers. httpClient is used for
+// discovery, JWKS and token requests; nil selects a client with a timeout.
+func NewOIDCService(
+	providers []OIDCProviderConfig,
+	userRepo *repository.UserRepository,
+	identityRepo *repository.IdentityRepository,
+	authService *AuthService,
+	passwords *PasswordService,
+	httpClient *http.Client,
+	stateKeys *signing.Keys,
+) *OIDCService {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: oidcHTTPTimeout}
+	}
+
+	byName := make(map[string]*oidcProvider, len(providers))
+	for _, p := range providers {
+		byName[p.Name] = &oidcProvider{config: p}
+	}
+
+	return &OIDCService{
+		providers:    byName,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+		passwords:    passwords,
+		httpClient:   httpClient,
+		stateKeys:    stateKeys,
+	}
+}
+
+type OIDCStart struct {
+	AuthURL string
+	// StateCookie must be returned to the browser and comes back on the
+	// callback; it carries the state, nonce and PKCE verifier.
+	StateCookie string
+}
+
+func (s *OIDCService) StartLogin(ctx context.Context, providerName string) (_ *OIDCStart, err error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.StartLogin")
+	defer func() { tracing.End(span, err) }()
+
+	return s.start(ctx, providerName, 0)
+}
+
+// StartLink begins linking an identity at providerName to userID, who is
+// already signed in. The callback then links the identity instead of
+// looking it up, so identities only join existing accounts on request.
+func (s *OIDCService) StartLink(ctx context.Context, providerName string, userID int) (_ *OIDCStart, err error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.StartLink")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, errors.New("account disabled")
+	}
+
+	return s.start(ctx, providerName, userID)
+}
+
+// start builds the redirect to the provider. A non-zero linkUserID is
+// carried in the state cookie for the callback.
+func (s *OIDCService) start(ctx context.Context, providerName string, linkUserID int) (*OIDCStart, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, errors.New("unknown identity provider")
+	}
+
+	oauthConfig, _, err := s.discover(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	claims := jwt.MapClaims{
+		"purpose":  oidcStatePurpose,
+		"provider": providerName,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      time.Now().Add(oidcStateLifetime).Unix(),
+		"iat":      time.Now().Unix(),
+	}
+	if linkUserID != 0 {
+		claims["link_user"] = strconv.Itoa(linkUserID)
+	}
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.stateKeys.Current())
+	if err != nil {
+		return nil, err
+	}
+
+	authURL := oauthConfig.AuthCodeURL(
+		state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(verifier),
+	)
+
+	return &OIDCStart{
+		AuthURL:     authURL,
+		StateCookie: cookie,
+	}, nil
+}
+
+func (s *OIDCService) CompleteLogin(ctx context.Context, providerName, code, state, stateCookie string) (_ *AuthResponse, err error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.CompleteLogin")
+	defer func() { tracing.End(span, err) }()
+
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, errors.New("unknown identity provider")
+	}
+
+	saved, err := s.parseStateCookie(stateCookie)
+	if err != nil || saved["provider"] != providerName || saved["state"] != state || state == "" {
+		return nil, errors.New("invalid login state")
+	}
+
+	oauthConfig, verifier, err := s.discover(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	httpCtx, cancel := context.WithTimeout(oidc.ClientContext(ctx, s.httpClient), oidcHTTPTimeout)
+	defer cancel()
+
+	token, err := oauthConfig.Exchange(httpCtx, code, oauth2.VerifierOption(saved["verifier"]))
+	if err != nil {
+		return nil, errors.New("code exchange failed")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("missing id_token")
+	}
+
+	idToken, err := verifier.Verify(httpCtx, rawIDToken)
+	if err != nil {
+		return nil, errors.New("invalid id_token")
+	}
+	if idToken.Nonce != saved["nonce"] {
+		return nil, errors.New("invalid id_token")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, errors.New("invalid id_token")
+	}
+
+	var user *models.User
+	if saved["link_user"] != "" {
+		linkUserID, err := strconv.Atoi(saved["link_user"])
+		if err != nil {
+			return nil, errors.New("invalid login state")
+		}
+		user, err = s.linkIdentity(ctx, providerName, idToken.Subject, claims.Email, linkUserID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		user, err = s.resolveUser(ctx, providerName, idToken.Subject, claims.Email, claims.PreferredUsername)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Same outcome as a password login, including the MFA challenge
+	return s.authService.completeExternalLogin(ctx, user)
+}
+
+// resolveUser finds the local user for an external identity: an existing
+// link, or else a freshly provisioned user. An email that belongs to an
+// account is refused; the owner links the identity with StartLink.
+func (s *OIDCService) resolveUser(ctx context.Context, provider, subject, email, preferredUsername string) (*models.User, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, provider, subject)
+	if err == nil {
+		return s.userRepo.GetByID(ctx, identity.UserID)
+	}
+	if err.Error() != "identity not found" {
+		return nil, err
+	}
+
+	if email == "" {
+		return nil, errors.New("identity provider did not supply an email")
+	}
+	user, err := s.provisionUser(ctx, email, preferredUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.identityRepo.Create(ctx, &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// linkIdentity links an external identity to userID, who started the flow
+// with StartLink. An identity already linked to another user is refused.
+func (s *OIDCService) linkIdentity(ctx context.Context, provider, subject, email string, userID int) (*models.User, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, provider, subject)
+	if err == nil {
+		if identity.UserID != userID {
+			return nil, errors.New("identity already linked")
+		}
+		return s.userRepo.GetByID(ctx, userID)
+	}
+	if err.Error() != "identity not found" {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.identityRepo.Create(ctx, &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *OIDCService) provisionUser(ctx context.Context, email, preferredUsername string) (*models.User, error) {
+	exists, err := s.userRepo.EmailExists(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		// An email claim never takes over a local account
+		return nil, errors.New("email already registered")
+	}
+
+	base := preferredUsername
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	base = usernameUnsafeChars.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	username := base
+	for i := 0; ; i++ {
+		exists, err := s.userRepo.UsernameExists(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			break
+		}
+		if i == 5 {
+			return nil, errors.New("could not allocate a username")
+		}
+		suffix, err := randomString(2)
+		if err != nil {
+			return nil, err
+		}
+		username = base + "-" + suffix
+	}
+
+	// Federated users sign in through their provider; the local password
+	// is random and never disclosed.
+	password, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     username,
+		Email:        email,
+		PasswordHash: hash,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *OIDCService) discover(ctx context.Context, p *oidcProvider) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	ctx, cancel := context.WithTimeout(oidc.ClientContext(ctx, s.httpClient), oidcHTTPTimeout)
+	defer cancel()
+
+	provider, err := oidc.NewProvider(ctx, p.config.IssuerURL)
+	if err != nil {
+		return nil, nil, errors.New("identity provider unavailable")
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+	// The verifier fetches and caches the provider JWKS, and keeps using
+	// our client through the remote key set.
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+
+	return p.oauth, p.verifier, nil
+}
+
+func (s *OIDCService) parseStateCookie(cookie string) (map[string]string, error) {
+	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return s.stateKeys.JWT(), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if purpose, _ := claims["purpose"].(string); purpose != oidcStatePurpose {
+		return nil, errors.New("invalid token")
+	}
+
+	saved := make(map[string]string)
+	for _, key := range []string{"provider", "state", "nonce", "verifier", "link_user"} {
+		value, _ := claims[key].(string)
+		saved[key] = value
+	}
+	return saved, nil
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
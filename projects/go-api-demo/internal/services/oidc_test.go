@@ -0,0 +1,452 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/signing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testOIDCProvider = "test"
+	testOIDCClientID = "go-api-demo"
+	testOIDCKeyID    = "test-key"
+)
+
+// testIssuer is an OpenID provider serving discovery, JWKS and a token
+// endpoint that checks the PKCE verifier against the authorize request.
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// signingKey, audience and the identity go into the next id_tokens
+	signingKey *rsa.PrivateKey
+	audience   string
+	subject    string
+	email      string
+	// grants maps an authorization code to its PKCE challenge and nonce
+	grants map[string]testGrant
+}
+
+type testGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{
+		key:        key,
+		signingKey: key,
+		audience:   testOIDCClientID,
+		subject:    "subject-1",
+		email:      "ada@example.com",
+		grants:     make(map[string]testGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	base := i.server.URL
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                base,
+		"authorization_endpoint":                base + "/authorize",
+		"token_endpoint":                        base + "/token",
+		"jwks_uri":                              base + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *testIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := i.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testOIDCKeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	grant, ok := i.grants[r.PostForm.Get("code")]
+	delete(i.grants, r.PostForm.Get("code"))
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                i.server.URL,
+		"sub":                i.subject,
+		"aud":                i.audience,
+		"email":              i.email,
+		"preferred_username": "ada",
+		"nonce":              grant.nonce,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = testOIDCKeyID
+	signed, err := idToken.SignedString(i.signingKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// authorize plays the user consenting at the provider: it records the
+// PKCE challenge and nonce of the authorize URL and returns the code and
+// state the provider redirects back with.
+func (i *testIssuer) authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorize URL without S256 PKCE challenge: %s", authURL)
+	}
+
+	code, err = randomString(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i.mu.Lock()
+	i.grants[code] = testGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	i.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+type fakeOIDCUsers struct {
+	users  map[int]*models.User
+	nextID int
+}
+
+func (f *fakeOIDCUsers) GetByID(ctx context.Context, id int) (*models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (f *fakeOIDCUsers) EmailExists(ctx context.Context, email string) (bool, error) {
+	for _, user := range f.users {
+		if user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeOIDCUsers) UsernameExists(ctx context.Context, username string) (bool, error) {
+	for _, user := range f.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeOIDCUsers) Create(ctx context.Context, user *models.User) error {
+	f.nextID++
+	user.ID = f.nextID
+	f.users[user.ID] = user
+	return nil
+}
+
+type fakeIdentities struct {
+	identities map[string]*models.UserIdentity
+}
+
+func (f *fakeIdentities) GetByProviderSubject(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	identity, ok := f.identities[provider+"/"+subject]
+	if !ok {
+		return nil, errors.New("identity not found")
+	}
+	return identity, nil
+}
+
+func (f *fakeIdentities) Create(ctx context.Context, identity *models.UserIdentity) error {
+	f.identities[identity.Provider+"/"+identity.Subject] = identity
+	return nil
+}
+
+// fakeSessions stands in for AuthService, answering with the user that
+// would be signed in.
+type fakeSessions struct{}
+
+func (fakeSessions) completeExternalLogin(ctx context.Context, user *models.User) (*AuthResponse, error) {
+	return &AuthResponse{
+		Token: "session-" + strconv.Itoa(user.ID),
+		User:  &UserResponse{ID: user.ID, Username: user.Username, Email: user.Email},
+	}, nil
+}
+
+type oidcTestEnv struct {
+	issuer     *testIssuer
+	service    *OIDCService
+	users      *fakeOIDCUsers
+	identities *fakeIdentities
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+
+	issuer := newTestIssuer(t)
+	passwords := NewPasswordService(nil, NewBcryptHasher(bcrypt.MinCost))
+	service := NewOIDCService(
+		[]OIDCProviderConfig{{
+			Name:        testOIDCProvider,
+			IssuerURL:   issuer.server.URL,
+			ClientID:    testOIDCClientID,
+			RedirectURL: "http://api.test/api/v1/auth/oidc/test/callback",
+		}},
+		nil,
+		nil,
+		nil,
+		passwords,
+		issuer.server.Client(),
+		signing.NewKeys([]byte("oidc-state-test-key")),
+	)
+
+	env := &oidcTestEnv{
+		issuer:     issuer,
+		service:    service,
+		users:      &fakeOIDCUsers{users: make(map[int]*models.User)},
+		identities: &fakeIdentities{identities: make(map[string]*models.UserIdentity)},
+	}
+	service.userRepo = env.users
+	service.identityRepo = env.identities
+	service.authService = fakeSessions{}
+
+	return env
+}
+
+// login runs start and callback against the test issuer.
+func (e *oidcTestEnv) login(t *testing.T) (*AuthResponse, error) {
+	t.Helper()
+
+	start, err := e.service.StartLogin(context.Background(), testOIDCProvider)
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+
+	code, state := e.issuer.authorize(t, start.AuthURL)
+	return e.service.CompleteLogin(context.Background(), testOIDCProvider, code, state, start.StateCookie)
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	response, err := env.login(t)
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+
+	if response.User == nil || response.User.Email != "ada@example.com" || response.User.Username != "ada" {
+		t.Fatalf("unexpected user: %+v", response.User)
+	}
+	identity, err := env.identities.GetByProviderSubject(context.Background(), testOIDCProvider, "subject-1")
+	if err != nil {
+		t.Fatalf("identity not stored: %v", err)
+	}
+	if identity.UserID != response.User.ID {
+		t.Fatalf("identity linked to user %d, want %d", identity.UserID, response.User.ID)
+	}
+}
+
+func TestOIDCLoginUsesLinkedIdentity(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	user := &models.User{Username: "grace", Email: "grace@example.com"}
+	env.users.Create(context.Background(), user)
+	env.identities.Create(context.Background(), &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: testOIDCProvider,
+		Subject:  "subject-1",
+	})
+
+	response, err := env.login(t)
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+
+	if response.User.ID != user.ID {
+		t.Fatalf("signed in user %d, want linked user %d", response.User.ID, user.ID)
+	}
+	if len(env.users.users) != 1 {
+		t.Fatalf("a linked login provisioned a new user")
+	}
+}
+
+func TestOIDCLoginRefusesRegisteredEmail(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	// Same email as the identity, but never linked to it
+	env.users.Create(context.Background(), &models.User{Username: "ada", Email: "ada@example.com"})
+
+	_, err := env.login(t)
+	if err == nil || err.Error() != "email already registered" {
+		t.Fatalf("got %v, want email already registered", err)
+	}
+	if len(env.identities.identities) != 0 {
+		t.Fatalf("identity linked by email")
+	}
+}
+
+func TestOIDCLinkIdentity(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	user := &models.User{Username: "ada", Email: "ada@example.com"}
+	env.users.Create(context.Background(), user)
+
+	start, err := env.service.StartLink(context.Background(), testOIDCProvider, user.ID)
+	if err != nil {
+		t.Fatalf("StartLink: %v", err)
+	}
+	code, state := env.issuer.authorize(t, start.AuthURL)
+
+	response, err := env.service.CompleteLogin(context.Background(), testOIDCProvider, code, state, start.StateCookie)
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+
+	if response.User.ID != user.ID {
+		t.Fatalf("signed in user %d, want %d", response.User.ID, user.ID)
+	}
+	identity, err := env.identities.GetByProviderSubject(context.Background(), testOIDCProvider, "subject-1")
+	if err != nil || identity.UserID != user.ID {
+		t.Fatalf("identity not linked to user %d: %+v, %v", user.ID, identity, err)
+	}
+}
+
+func TestOIDCLinkRefusesIdentityOfAnotherUser(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	owner := &models.User{Username: "grace", Email: "grace@example.com"}
+	env.users.Create(context.Background(), owner)
+	env.identities.Create(context.Background(), &models.UserIdentity{
+		UserID:   owner.ID,
+		Provider: testOIDCProvider,
+		Subject:  "subject-1",
+	})
+	other := &models.User{Username: "ada", Email: "ada@example.com"}
+	env.users.Create(context.Background(), other)
+
+	start, err := env.service.StartLink(context.Background(), testOIDCProvider, other.ID)
+	if err != nil {
+		t.Fatalf("StartLink: %v", err)
+	}
+	code, state := env.issuer.authorize(t, start.AuthURL)
+
+	_, err = env.service.CompleteLogin(context.Background(), testOIDCProvider, code, state, start.StateCookie)
+	if err == nil || err.Error() != "identity already linked" {
+		t.Fatalf("got %v, want identity already linked", err)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	start, err := env.service.StartLogin(context.Background(), testOIDCProvider)
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	code, _ := env.issuer.authorize(t, start.AuthURL)
+
+	_, err = env.service.CompleteLogin(context.Background(), testOIDCProvider, code, "forged-state", start.StateCookie)
+	if err == nil || err.Error() != "invalid login state" {
+		t.Fatalf("got %v, want invalid login state", err)
+	}
+}
+
+func TestOIDCCallbackRejectsBadIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(i *testIssuer)
+	}{
+		{"signature", func(i *testIssuer) { i.signingKey = otherKey }},
+		{"audience", func(i *testIssuer) { i.audience = "another-client" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			tt.tamper(env.issuer)
+
+			_, err := env.login(t)
+			if err == nil || err.Error() != "invalid id_token" {
+				t.Fatalf("got %v, want invalid id_token", err)
+			}
+			if len(env.users.users) != 0 {
+				t.Fatalf("user provisioned from a rejected id_token")
+			}
+		})
+	}
+}
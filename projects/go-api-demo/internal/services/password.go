@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher produces and checks self-describing encoded hashes
+// (PHC string format for argon2id, modular crypt format for bcrypt).
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// Supports reports whether encoded was produced by this algorithm,
+	// whatever its parameters.
+	Supports(encoded string) bool
+	// NeedsRehash reports whether encoded differs from what Hash would
+	// produce today (other algorithm or outdated parameters).
+	NeedsRehash(encoded string) bool
+}
+
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func NewArgon2idHasher(memory, iterations uint32, parallelism uint8) *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Memory,
+		h.Iterations,
+		h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.Memory ||
+		params.Iterations != h.Iterations ||
+		params.Parallelism != h.Parallelism ||
+		uint32(len(salt)) != h.SaltLength ||
+		uint32(len(key)) != h.KeyLength
+}
+
+func decodeArgon2id(encoded string) (*Argon2idHasher, []byte, []byte, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, errors.New("unsupported argon2 version")
+	}
+
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	return params, salt, key, nil
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h *BcryptHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// PasswordService hashes new passwords with the preferred hasher, still
+// verifies hashes made by the fallbacks, and enforces the password policy.
+type PasswordService struct {
+	preferred PasswordHasher
+	hashers   []PasswordHasher
+	policy    *PasswordPolicy
+
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+func NewPasswordService(policy *PasswordPolicy, preferred PasswordHasher, fallbacks ...PasswordHasher) *PasswordService {
+	return &PasswordService{
+		preferred: preferred,
+		hashers:   append([]PasswordHasher{preferred}, fallbacks...),
+		policy:    policy,
+	}
+}
+
+func (s *PasswordService) Hash(password string) (string, error) {
+	return s.preferred.Hash(password)
+}
+
+// Verify checks password against encoded and reports whether a matching
+// hash should be upgraded to the preferred algorithm and parameters.
+func (s *PasswordService) Verify(password, encoded string) (bool, bool, error) {
+	for _, hasher := range s.hashers {
+		if !hasher.Supports(encoded) {
+			continue
+		}
+
+		ok, err := hasher.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+
+		return true, s.preferred.NeedsRehash(encoded), nil
+	}
+
+	return false, false, errors.New("unsupported password hash")
+}
+
+// VerifyDummy burns the same time as a real verification, so rejecting an
+// unknown account is indistinguishable from rejecting a wrong password.
+func (s *PasswordService) VerifyDummy(password string) {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = s.preferred.Hash("dummy-password")
+	})
+	s.preferred.Verify(password, s.dummyHash)
+}
+
+func (s *PasswordService) Validate(username, password string) error {
+	return s.policy.Validate(username, password)
+}
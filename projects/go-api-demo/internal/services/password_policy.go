@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+type PasswordPolicy struct {
+	MinLength int
+	// BreachedList is optional; nil disables the breached-password check.
+	BreachedList *BreachedPasswordList
+}
+
+func (p *PasswordPolicy) Validate(username, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return errors.New("password too short")
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("password must not contain the username")
+	}
+
+	if p.BreachedList != nil {
+		breached, err := p.BreachedList.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return errors.New("password appears in a known data breach")
+		}
+	}
+
+	return nil
+}
+
+// BreachedPasswordList looks passwords up in a local copy of the Have I
+// Been Pwned SHA-1 list ("HASH:COUNT" lines, ordered by hash), the same
+// data the k-anonymity range API serves. The file is binary searched on
+// disk, so the full list never has to fit in memory.
+type BreachedPasswordList struct {
+	path string
+}
+
+func NewBreachedPasswordList(path string) (*BreachedPasswordList, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("error opening breached password list: %w", err)
+	}
+	return &BreachedPasswordList{path: path}, nil
+}
+
+func (l *BreachedPasswordList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	// Smallest offset whose following line sorts at or after target
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := lineAfter(f, mid)
+		if err != nil {
+			return false, err
+		}
+		if line != "" && lineHash(line) < target {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	line, err := lineAfter(f, lo)
+	if err != nil {
+		return false, err
+	}
+	return line != "" && lineHash(line) == target, nil
+}
+
+// lineAfter returns the first complete line starting at or after offset,
+// or "" at end of file.
+func lineAfter(f *os.File, offset int64) (string, error) {
+	start := offset
+	if start > 0 {
+		start--
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, start, math.MaxInt64-start))
+
+	// Skip the partial line we landed in, unless offset is a line start
+	if offset > 0 {
+		if _, err := r.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", err
+		}
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func lineHash(line string) string {
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.ToUpper(line)
+}
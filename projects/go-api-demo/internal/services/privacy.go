@@ -0,0 +1,395 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/logging"
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/signing"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+// exportTimeout bounds the background job building an archive; the
+// export is marked failed when it runs out.
+const exportTimeout = 10 * time.Minute
+
+type ExportPolicy struct {
+	// Dir holds the generated archives until they expire.
+	Dir string
+	// Retention is how long an archive is kept after it is built.
+	Retention time.Duration
+	// LinkTTL is how long a signed download link stays valid.
+	LinkTTL time.Duration
+	// DownloadURL is the public path of the download endpoint; the export
+	// id, expiry and signature are appended to it.
+	DownloadURL string
+	SigningKeys *signing.Keys
+}
+
+// PrivacyService answers data-subject requests: exporting everything held
+// about a user and erasing it.
+type PrivacyService struct {
+	exportRepo *repository.ExportRepository
+	userRepo   *repository.UserRepository
+	taskRepo   *repository.TaskRepository
+	auditRepo  *repository.AuditRepository
+	workspaces *WorkspaceService
+	policy     ExportPolicy
+}
+
+func NewPrivacyService(
+	exportRepo *repository.ExportRepository,
+	userRepo *repository.UserRepository,
+	taskRepo *repository.TaskRepository,
+	auditRepo *repository.AuditRepository,
+	workspaces *WorkspaceService,
+	policy ExportPolicy,
+) *PrivacyService {
+	return &PrivacyService{
+		exportRepo: exportRepo,
+		userRepo:   userRepo,
+		taskRepo:   taskRepo,
+		auditRepo:  auditRepo,
+		workspaces: workspaces,
+		policy:     policy,
+	}
+}
+
+type ExportResponse struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// RequestExport queues an export of the user's data; the archive is built
+// in the background and its status is polled with GetExport.
+func (s *PrivacyService) RequestExport(ctx context.Context, userID int) (_ *ExportResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PrivacyService.RequestExport")
+	defer func() { tracing.End(span, err) }()
+
+	pending, err := s.exportRepo.HasPending(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, errors.New("export already in progress")
+	}
+
+	export := &models.DataExport{UserID: userID}
+	if err := s.exportRepo.Create(ctx, export); err != nil {
+		return nil, err
+	}
+
+	// The archive outlives the request: keep its request id and trace for
+	// the logs and queries, but not its cancellation or deadline
+	go s.runExport(context.WithoutCancel(ctx), export.ID, userID)
+
+	return s.exportToResponse(export), nil
+}
+
+func (s *PrivacyService) GetExport(ctx context.Context, id, userID int) (_ *ExportResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PrivacyService.GetExport")
+	defer func() { tracing.End(span, err) }()
+
+	export, err := s.exportRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if export.UserID != userID {
+		return nil, errors.New("export not found")
+	}
+
+	return s.exportToResponse(export), nil
+}
+
+// OpenDownload checks a signed download link and returns the archive path
+// on disk.
+func (s *PrivacyService) OpenDownload(ctx context.Context, id int, expires, signature string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "PrivacyService.OpenDownload")
+	defer func() { tracing.End(span, err) }()
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", errors.New("download link expired")
+	}
+
+	if !s.verify(id, expiresAt, signature) {
+		return "", errors.New("invalid download link")
+	}
+
+	export, err := s.exportRepo.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if export.Status != models.ExportStatusCompleted || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return "", errors.New("export not found")
+	}
+
+	return filepath.Join(s.policy.Dir, export.FileName), nil
+}
+
+// EraseUser permanently removes the user and their exports. History that
+// must be retained is anonymized instead of deleted (see
+// UserRepository.Erase). The last owner of a shared workspace is refused.
+func (s *PrivacyService) EraseUser(ctx context.Context, userID int) (err error) {
+	ctx, span := tracing.Start(ctx, "PrivacyService.EraseUser")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.workspaces.KeepOwners(ctx, userID); err != nil {
+		return err
+	}
+
+	files, err := s.exportRepo.GetFilesByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Erase(ctx, user.ID, user.Username); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		os.Remove(filepath.Join(s.policy.Dir, filepath.Base(file)))
+	}
+
+	return nil
+}
+
+// PurgeExpiredExports deletes archives whose retention period has ended.
+func (s *PrivacyService) PurgeExpiredExports(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "PrivacyService.PurgeExpiredExports")
+	defer func() { tracing.End(span, err) }()
+
+	files, err := s.exportRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		os.Remove(filepath.Join(s.policy.Dir, filepath.Base(file)))
+	}
+
+	return nil
+}
+
+func (s *PrivacyService) runExport(ctx context.Context, exportID, userID int) {
+	buildCtx, cancel := context.WithTimeout(ctx, exportTimeout)
+	fileName, err := s.buildArchive(buildCtx, exportID, userID)
+	cancel()
+
+	logger := logging.FromContext(ctx)
+	if err != nil {
+		logger.Error("data export failed", "export_id", exportID, "user_id", userID, "error", err)
+		s.exportRepo.Fail(ctx, exportID, "export could not be generated")
+		return
+	}
+
+	if err := s.exportRepo.Complete(ctx, exportID, fileName, time.Now().Add(s.policy.Retention)); err != nil {
+		logger.Error("data export failed", "export_id", exportID, "user_id", userID, "error", err)
+		os.Remove(filepath.Join(s.policy.Dir, fileName))
+		s.exportRepo.Fail(ctx, exportID, "export could not be generated")
+	}
+}
+
+func (s *PrivacyService) buildArchive(ctx context.Context, exportID, userID int) (string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	tasks, err := s.taskRepo.ForUser(userID).GetByUserID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	events, err := s.auditRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	suffix, err := randomString(8)
+	if err != nil {
+		return "", err
+	}
+	fileName := fmt.Sprintf("export-%d-%s.zip", exportID, suffix)
+
+	if err := os.MkdirAll(s.policy.Dir, 0o700); err != nil {
+		return "", err
+	}
+	file, err := os.OpenFile(filepath.Join(s.policy.Dir, fileName), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeExportArchive(file, profileResponse(user), tasks, events)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filepath.Join(s.policy.Dir, fileName))
+		return "", err
+	}
+
+	return fileName, nil
+}
+
+func writeExportArchive(file *os.File, profile *UserResponse, tasks []*models.Task, events []*models.AuditEvent) error {
+	archive := zip.NewWriter(file)
+
+	profileRows := [][]string{
+		{"id", "username", "email", "name", "avatar_url", "created_at"},
+		{
+			strconv.Itoa(profile.ID),
+			profile.Username,
+			profile.Email,
+			profile.Name,
+			profile.AvatarURL,
+			profile.CreatedAt.UTC().Format(time.RFC3339),
+		},
+	}
+
+	taskRows := [][]string{{"id", "title", "description", "status", "due_date", "created_at", "updated_at"}}
+	for _, task := range tasks {
+		dueDate := ""
+		if task.DueDate != nil {
+			dueDate = task.DueDate.UTC().Format(time.RFC3339)
+		}
+		taskRows = append(taskRows, []string{
+			fmt.Sprint(task.ID),
+			task.Title,
+			task.Description,
+			fmt.Sprint(task.Status),
+			dueDate,
+			task.CreatedAt.UTC().Format(time.RFC3339),
+			task.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	eventRows := [][]string{{"id", "action", "ip_address", "metadata", "created_at"}}
+	for _, event := range events {
+		eventRows = append(eventRows, []string{
+			strconv.Itoa(event.ID),
+			event.Action,
+			event.IPAddress,
+			formatMetadata(event.Metadata),
+			event.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	entries := []struct {
+		name string
+		data interface{}
+		rows [][]string
+	}{
+		{"profile", profile, profileRows},
+		{"tasks", tasks, taskRows},
+		{"audit_events", events, eventRows},
+	}
+
+	for _, entry := range entries {
+		w, err := archive.Create(entry.name + ".json")
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entry.data); err != nil {
+			return err
+		}
+
+		w, err = archive.Create(entry.name + ".csv")
+		if err != nil {
+			return err
+		}
+		if err := csv.NewWriter(w).WriteAll(entry.rows); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func (s *PrivacyService) exportToResponse(export *models.DataExport) *ExportResponse {
+	response := &ExportResponse{
+		ID:        export.ID,
+		Status:    export.Status,
+		Error:     export.Error,
+		ExpiresAt: export.ExpiresAt,
+		CreatedAt: export.CreatedAt,
+	}
+
+	if export.Status == models.ExportStatusCompleted && export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt) {
+		// The link never outlives the archive itself
+		linkExpiry := time.Now().Add(s.policy.LinkTTL)
+		if linkExpiry.After(*export.ExpiresAt) {
+			linkExpiry = *export.ExpiresAt
+		}
+		expires := linkExpiry.Unix()
+
+		params := url.Values{}
+		params.Set("expires", strconv.FormatInt(expires, 10))
+		params.Set("signature", s.sign(export.ID, expires))
+		response.DownloadURL = strings.Replace(s.policy.DownloadURL, ":id", strconv.Itoa(export.ID), 1) + "?" + params.Encode()
+	}
+
+	return response
+}
+
+func (s *PrivacyService) sign(id int, expires int64) string {
+	return exportSignature(s.policy.SigningKeys.Current(), id, expires)
+}
+
+// verify accepts links signed with the previous key too, so rotating the
+// key does not break links already handed out.
+func (s *PrivacyService) verify(id int, expires int64, signature string) bool {
+	for _, key := range s.policy.SigningKeys.Verification() {
+		if hmac.Equal([]byte(exportSignature(key, id, expires)), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+func exportSignature(key []byte, id int, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "data-export:%d:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + metadata[key]
+	}
+	return strings.Join(pairs, ";")
+}
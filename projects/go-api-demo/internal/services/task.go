@@ -1,20 +1,31 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"strconv"
 	"time"
 
+	"github.com/malex1718/go-api-demo/internal/metrics"
 	"github.com/malex1718/go-api-demo/internal/models"
 	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/tracing"
 )
 
+// TaskService authorizes every call against the workspace the task lives
+// in: viewers can read, members and above can write. A workspaceID of 0
+// means the caller's personal workspace. The ctx of each call is the
+// request's, and reaches the SQL it runs; each call is traced as a child
+// span of the request.
 type TaskService struct {
-	taskRepo *repository.TaskRepository
+	taskRepo   *repository.TaskRepository
+	workspaces *WorkspaceService
 }
 
-func NewTaskService(taskRepo *repository.TaskRepository) *TaskService {
+func NewTaskService(taskRepo *repository.TaskRepository, workspaces *WorkspaceService) *TaskService {
 	return &TaskService{
-		taskRepo: taskRepo,
+		taskRepo:   taskRepo,
+		workspaces: workspaces,
 	}
 }
 
@@ -30,23 +41,52 @@ type UpdateTaskInput struct {
 	Status      string `json:"status" validate:"required,oneof=pending in_progress completed"`
 }
 
+// TaskListInput filters a task listing. Assignee is "me", "none" or a
+// user id.
+type TaskListInput struct {
+	Status   string
+	Assignee string
+}
+
+type AssignTaskInput struct {
+	// AssigneeID is the member to assign; null unassigns the task
+	AssigneeID *int `json:"assignee_id"`
+}
+
 type TaskResponse struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	UserID      int       `json:"user_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	UserID      *int       `json:"user_id"`
+	WorkspaceID int        `json:"workspace_id"`
+	AssigneeID  *int       `json:"assignee_id"`
+	AssignedAt  *time.Time `json:"assigned_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type TaskStatistics struct {
-	Total      int            `json:"total"`
-	ByStatus   map[string]int `json:"by_status"`
-	LastUpdate time.Time      `json:"last_update"`
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	// Created and Assigned count, by status, the tasks the caller created
+	// and the tasks assigned to them
+	Created  map[string]int `json:"created"`
+	Assigned map[string]int `json:"assigned"`
+	// ByAssignee is only filled in when grouping by assignee was requested
+	ByAssignee map[string]map[string]int `json:"by_assignee,omitempty"`
+	LastUpdate time.Time                 `json:"last_update"`
 }
 
-func (s *TaskService) CreateTask(userID int, input CreateTaskInput) (*TaskResponse, error) {
+func (s *TaskService) CreateTask(ctx context.Context, workspaceID, userID int, input CreateTaskInput) (_ *TaskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.CreateTask")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleMember)
+	if err != nil {
+		return nil, err
+	}
+
 	// Default status if not provided
 	if input.Status == "" {
 		input.Status = "pending"
@@ -56,28 +96,33 @@ func (s *TaskService) CreateTask(userID int, input CreateTaskInput) (*TaskRespon
 		Title:       input.Title,
 		Description: input.Description,
 		Status:      input.Status,
-		UserID:      userID,
+		UserID:      &userID,
+		WorkspaceID: workspaceID,
 	}
 
-	err := s.taskRepo.Create(task)
+	err = s.taskRepo.ForUser(userID).Create(ctx, task)
 	if err != nil {
 		return nil, err
 	}
 
+	metrics.TasksCreated.Inc()
+	if task.Status == "completed" {
+		metrics.TasksCompleted.Inc()
+	}
+
 	return s.taskToResponse(task), nil
 }
 
-func (s *TaskService) GetTaskByID(taskID, userID int) (*TaskResponse, error) {
-	// Check if task belongs to user
-	belongs, err := s.taskRepo.BelongsToUser(taskID, userID)
+func (s *TaskService) GetTaskByID(ctx context.Context, workspaceID, taskID, userID int) (_ *TaskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.GetTaskByID")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleViewer)
 	if err != nil {
 		return nil, err
 	}
-	if !belongs {
-		return nil, errors.New("task not found or unauthorized")
-	}
 
-	task, err := s.taskRepo.GetByID(taskID)
+	task, err := s.taskRepo.ForUser(userID).GetByID(ctx, taskID, workspaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -85,45 +130,129 @@ func (s *TaskService) GetTaskByID(taskID, userID int) (*TaskResponse, error) {
 	return s.taskToResponse(task), nil
 }
 
-func (s *TaskService) GetUserTasks(userID int, status string) ([]*TaskResponse, error) {
-	var tasks []*models.Task
-	var err error
+func (s *TaskService) GetUserTasks(ctx context.Context, workspaceID, userID int, input TaskListInput) (_ []*TaskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.GetUserTasks")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := models.TaskFilter{}
 
-	if status != "" {
+	if input.Status != "" {
 		// Validate status
 		validStatuses := map[string]bool{
 			"pending":     true,
 			"in_progress": true,
 			"completed":   true,
 		}
-		if !validStatuses[status] {
+		if !validStatuses[input.Status] {
 			return nil, errors.New("invalid status filter")
 		}
-		tasks, err = s.taskRepo.GetByStatus(userID, status)
-	} else {
-		tasks, err = s.taskRepo.GetByUserID(userID)
+		filter.Status = models.TaskStatus(input.Status)
 	}
 
+	switch input.Assignee {
+	case "":
+	case "me":
+		filter.AssigneeID = &userID
+	case "none":
+		filter.Unassigned = true
+	default:
+		assigneeID, err := strconv.Atoi(input.Assignee)
+		if err != nil || assigneeID < 1 {
+			return nil, errors.New("invalid assignee filter")
+		}
+		filter.AssigneeID = &assigneeID
+	}
+
+	tasks, err := s.taskRepo.ForUser(userID).GetFiltered(ctx, workspaceID, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	responses := make([]*TaskResponse, len(tasks))
-	for i, task := range tasks {
-		responses[i] = s.taskToResponse(task)
+	return s.tasksToResponses(tasks), nil
+}
+
+// GetAssignedTasks lists the tasks assigned to the user across all of
+// their workspaces.
+func (s *TaskService) GetAssignedTasks(ctx context.Context, userID int) (_ []*TaskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.GetAssignedTasks")
+	defer func() { tracing.End(span, err) }()
+
+	tasks, err := s.taskRepo.ForUser(userID).GetAssignedTo(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tasksToResponses(tasks), nil
+}
+
+// AssignTask hands the task to another member of its workspace, or
+// unassigns it. Viewers cannot be assigned since they cannot update tasks.
+func (s *TaskService) AssignTask(ctx context.Context, workspaceID, taskID, userID int, input AssignTaskInput) (_ *TaskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.AssignTask")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.taskRepo.ForUser(userID).GetByID(ctx, taskID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.AssigneeID != nil {
+		if _, err := s.workspaces.Authorize(ctx, workspaceID, *input.AssigneeID, models.WorkspaceRoleMember); err != nil {
+			return nil, errors.New("assignee must be a member of the workspace")
+		}
+	}
+
+	// Nothing to record if the assignee does not change
+	if sameAssignee(task.AssigneeID, input.AssigneeID) {
+		return s.taskToResponse(task), nil
+	}
+
+	task.AssigneeID = input.AssigneeID
+	if err := s.taskRepo.ForUser(userID).Assign(ctx, task, userID); err != nil {
+		return nil, err
+	}
+
+	return s.taskToResponse(task), nil
+}
+
+func (s *TaskService) GetAssignmentHistory(ctx context.Context, workspaceID, taskID, userID int) (_ []*models.TaskAssignment, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.GetAssignmentHistory")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.taskRepo.ForUser(userID).GetByID(ctx, taskID, workspaceID); err != nil {
+		return nil, err
 	}
 
-	return responses, nil
+	return s.taskRepo.ForUser(userID).GetAssignments(ctx, taskID, workspaceID)
 }
 
-func (s *TaskService) UpdateTask(taskID, userID int, input UpdateTaskInput) (*TaskResponse, error) {
-	// Check if task belongs to user
-	belongs, err := s.taskRepo.BelongsToUser(taskID, userID)
+func (s *TaskService) UpdateTask(ctx context.Context, workspaceID, taskID, userID int, input UpdateTaskInput) (_ *TaskResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.UpdateTask")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleMember)
 	if err != nil {
 		return nil, err
 	}
-	if !belongs {
-		return nil, errors.New("task not found or unauthorized")
+
+	previous, err := s.taskRepo.ForUser(userID).GetByID(ctx, taskID, workspaceID)
+	if err != nil {
+		return nil, err
 	}
 
 	task := &models.Task{
@@ -131,16 +260,20 @@ func (s *TaskService) UpdateTask(taskID, userID int, input UpdateTaskInput) (*Ta
 		Title:       input.Title,
 		Description: input.Description,
 		Status:      input.Status,
-		UserID:      userID,
+		WorkspaceID: workspaceID,
 	}
 
-	err = s.taskRepo.Update(task)
+	err = s.taskRepo.ForUser(userID).Update(ctx, task)
 	if err != nil {
 		return nil, err
 	}
 
+	if task.Status == "completed" && previous.Status != task.Status {
+		metrics.TasksCompleted.Inc()
+	}
+
 	// Get updated task
-	updated, err := s.taskRepo.GetByID(taskID)
+	updated, err := s.taskRepo.ForUser(userID).GetByID(ctx, taskID, workspaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -148,23 +281,57 @@ func (s *TaskService) UpdateTask(taskID, userID int, input UpdateTaskInput) (*Ta
 	return s.taskToResponse(updated), nil
 }
 
-func (s *TaskService) DeleteTask(taskID, userID int) error {
-	return s.taskRepo.Delete(taskID, userID)
+func (s *TaskService) DeleteTask(ctx context.Context, workspaceID, taskID, userID int) (err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.DeleteTask")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleMember)
+	if err != nil {
+		return err
+	}
+
+	return s.taskRepo.ForUser(userID).Delete(ctx, taskID, workspaceID)
 }
 
-func (s *TaskService) GetUserStatistics(userID int) (*TaskStatistics, error) {
-	statusCounts, err := s.taskRepo.CountByStatus(userID)
+func (s *TaskService) GetUserStatistics(ctx context.Context, workspaceID, userID int, groupByAssignee bool) (_ *TaskStatistics, err error) {
+	ctx, span := tracing.Start(ctx, "TaskService.GetUserStatistics")
+	defer func() { tracing.End(span, err) }()
+
+	workspaceID, err = s.workspaces.resolve(ctx, workspaceID, userID, models.WorkspaceRoleViewer)
 	if err != nil {
 		return nil, err
 	}
 
+	statusCounts, err := s.taskRepo.ForUser(userID).CountByStatus(ctx, workspaceID, models.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.taskRepo.ForUser(userID).CountByStatus(ctx, workspaceID, models.TaskFilter{CreatedBy: &userID})
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := s.taskRepo.ForUser(userID).CountByStatus(ctx, workspaceID, models.TaskFilter{AssigneeID: &userID})
+	if err != nil {
+		return nil, err
+	}
+
+	var byAssignee map[string]map[string]int
+	if groupByAssignee {
+		byAssignee, err = s.taskRepo.ForUser(userID).CountByAssignee(ctx, workspaceID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	total := 0
 	for _, count := range statusCounts {
 		total += count
 	}
 
 	// Get most recent task to determine last update
-	tasks, err := s.taskRepo.GetByUserID(userID)
+	tasks, err := s.taskRepo.ForUser(userID).GetByWorkspace(ctx, workspaceID)
 	var lastUpdate time.Time
 	if err == nil && len(tasks) > 0 {
 		lastUpdate = tasks[0].UpdatedAt
@@ -173,6 +340,9 @@ func (s *TaskService) GetUserStatistics(userID int) (*TaskStatistics, error) {
 	return &TaskStatistics{
 		Total:      total,
 		ByStatus:   statusCounts,
+		Created:    created,
+		Assigned:   assigned,
+		ByAssignee: byAssignee,
 		LastUpdate: lastUpdate,
 	}, nil
 }
@@ -184,7 +354,25 @@ func (s *TaskService) taskToResponse(task *models.Task) *TaskResponse {
 		Description: task.Description,
 		Status:      task.Status,
 		UserID:      task.UserID,
+		WorkspaceID: task.WorkspaceID,
+		AssigneeID:  task.AssigneeID,
+		AssignedAt:  task.AssignedAt,
 		CreatedAt:   task.CreatedAt,
 		UpdatedAt:   task.UpdatedAt,
 	}
+}
+
+func (s *TaskService) tasksToResponses(tasks []*models.Task) []*TaskResponse {
+	responses := make([]*TaskResponse, len(tasks))
+	for i, task := range tasks {
+		responses[i] = s.taskToResponse(task)
+	}
+	return responses
+}
+
+func sameAssignee(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
\ No newline at end of file
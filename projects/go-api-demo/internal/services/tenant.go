@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+// Slugs double as subdomains and schema names, so they follow DNS label
+// rules
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,46}[a-z0-9])?$`)
+
+// TenantService manages tenants and resolves them for incoming requests.
+// Lookups are cached for cacheTTL, which bounds how long a suspension or a
+// new rate limit takes to reach a running server.
+type TenantService struct {
+	tenantRepo *repository.TenantRepository
+	perSchema  bool
+	cacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedTenant
+}
+
+type cachedTenant struct {
+	tenant    *models.Tenant
+	expiresAt time.Time
+}
+
+func NewTenantService(tenantRepo *repository.TenantRepository, perSchema bool, cacheTTL time.Duration) *TenantService {
+	return &TenantService{
+		tenantRepo: tenantRepo,
+		perSchema:  perSchema,
+		cacheTTL:   cacheTTL,
+		cache:      make(map[string]cachedTenant),
+	}
+}
+
+type CreateTenantInput struct {
+	Slug string
+	Name string
+	TenantLimits
+}
+
+// TenantLimits are a tenant's quotas and rate limit. Nil quotas and a zero
+// rate limit mean unlimited and the server default.
+type TenantLimits struct {
+	MaxUsers  *int
+	MaxTasks  *int
+	RateLimit int
+}
+
+// Resolve returns the tenant for slug, from the cache when possible.
+func (s *TenantService) Resolve(ctx context.Context, slug string) (_ *models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.Resolve")
+	defer func() { tracing.End(span, err) }()
+
+	s.mu.Lock()
+	entry, ok := s.cache[slug]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.tenant, nil
+	}
+
+	tenant, err := s.tenantRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[slug] = cachedTenant{tenant: tenant, expiresAt: time.Now().Add(s.cacheTTL)}
+	s.mu.Unlock()
+
+	return tenant, nil
+}
+
+// CreateTenant registers a tenant. In schema-per-tenant mode its schema is
+// created and the migrations are run inside it.
+func (s *TenantService) CreateTenant(ctx context.Context, input CreateTenantInput, migrations []string) (_ *models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.CreateTenant")
+	defer func() { tracing.End(span, err) }()
+
+	if !tenantSlugPattern.MatchString(input.Slug) {
+		return nil, errors.New("invalid tenant slug")
+	}
+	if err := validateLimits(input.TenantLimits); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.tenantRepo.GetBySlug(ctx, input.Slug); err == nil {
+		return nil, errors.New("tenant already exists")
+	} else if err.Error() != "tenant not found" {
+		return nil, err
+	}
+
+	name := input.Name
+	if name == "" {
+		name = input.Slug
+	}
+
+	tenant := &models.Tenant{
+		Slug:      input.Slug,
+		Name:      name,
+		Status:    models.TenantStatusActive,
+		MaxUsers:  input.MaxUsers,
+		MaxTasks:  input.MaxTasks,
+		RateLimit: input.RateLimit,
+	}
+
+	if !s.perSchema {
+		if err := s.tenantRepo.Create(ctx, tenant); err != nil {
+			return nil, err
+		}
+		return tenant, nil
+	}
+
+	tenant.SchemaName = tenantSchemaName(input.Slug)
+	if err := s.tenantRepo.CreateWithSchema(ctx, tenant, migrations); err != nil {
+		return nil, err
+	}
+
+	return tenant, nil
+}
+
+func (s *TenantService) ListTenants(ctx context.Context) (_ []*models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.ListTenants")
+	defer func() { tracing.End(span, err) }()
+
+	return s.tenantRepo.List(ctx)
+}
+
+func (s *TenantService) GetTenant(ctx context.Context, slug string) (_ *models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.GetTenant")
+	defer func() { tracing.End(span, err) }()
+
+	return s.tenantRepo.GetBySlug(ctx, slug)
+}
+
+// SuspendTenant blocks every request to the tenant without touching its
+// data.
+func (s *TenantService) SuspendTenant(ctx context.Context, slug string) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.SuspendTenant")
+	defer func() { tracing.End(span, err) }()
+
+	return s.setStatus(ctx, slug, models.TenantStatusSuspended)
+}
+
+func (s *TenantService) ResumeTenant(ctx context.Context, slug string) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.ResumeTenant")
+	defer func() { tracing.End(span, err) }()
+
+	return s.setStatus(ctx, slug, models.TenantStatusActive)
+}
+
+func (s *TenantService) UpdateLimits(ctx context.Context, slug string, limits TenantLimits) (_ *models.Tenant, err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.UpdateLimits")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateLimits(limits); err != nil {
+		return nil, err
+	}
+
+	tenant, err := s.tenantRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant.MaxUsers = limits.MaxUsers
+	tenant.MaxTasks = limits.MaxTasks
+	tenant.RateLimit = limits.RateLimit
+	if err := s.tenantRepo.UpdateLimits(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	s.forget(slug)
+	return tenant, nil
+}
+
+// DeleteTenant permanently removes the tenant and all of its data.
+func (s *TenantService) DeleteTenant(ctx context.Context, slug string) (err error) {
+	ctx, span := tracing.Start(ctx, "TenantService.DeleteTenant")
+	defer func() { tracing.End(span, err) }()
+
+	if slug == models.DefaultTenantSlug {
+		return errors.New("default tenant cannot be deleted")
+	}
+
+	tenant, err := s.tenantRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tenantRepo.Delete(ctx, tenant); err != nil {
+		return err
+	}
+
+	s.forget(slug)
+	return nil
+}
+
+func (s *TenantService) setStatus(ctx context.Context, slug, status string) error {
+	tenant, err := s.tenantRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tenantRepo.UpdateStatus(ctx, tenant.ID, status); err != nil {
+		return err
+	}
+
+	s.forget(slug)
+	return nil
+}
+
+func (s *TenantService) forget(slug string) {
+	s.mu.Lock()
+	delete(s.cache, slug)
+	s.mu.Unlock()
+}
+
+func validateLimits(limits TenantLimits) error {
+	if (limits.MaxUsers != nil && *limits.MaxUsers < 0) || (limits.MaxTasks != nil && *limits.MaxTasks < 0) || limits.RateLimit < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
+
+// tenantSchemaName maps a slug to its schema; hyphens would need quoting
+// in every hand-written query.
+func tenantSchemaName(slug string) string {
+	return "tenant_" + strings.ReplaceAll(slug, "-", "_")
+}
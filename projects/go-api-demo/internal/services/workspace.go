@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/malex1718/go-api-demo/internal/models"
+	"github.com/malex1718/go-api-demo/internal/repository"
+	"github.com/malex1718/go-api-demo/internal/tracing"
+)
+
+const (
+	invitationLifetime    = 7 * 24 * time.Hour
+	personalWorkspaceName = "Personal"
+)
+
+type WorkspaceService struct {
+	workspaceRepo *repository.WorkspaceRepository
+	userRepo      *repository.UserRepository
+}
+
+func NewWorkspaceService(workspaceRepo *repository.WorkspaceRepository, userRepo *repository.UserRepository) *WorkspaceService {
+	return &WorkspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+	}
+}
+
+type WorkspaceInput struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type UpdateMemberInput struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member viewer"`
+}
+
+type InviteInput struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=admin member viewer"`
+}
+
+// Authorize checks that the user belongs to the workspace with at least
+// minRole and returns their role. Non-members get the same error as for a
+// missing workspace, so workspace ids cannot be probed.
+func (s *WorkspaceService) Authorize(ctx context.Context, workspaceID, userID int, minRole string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Authorize")
+	defer func() { tracing.End(span, err) }()
+
+	role, err := s.workspaceRepo.GetMemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		if err.Error() == "membership not found" {
+			return "", errors.New("workspace not found")
+		}
+		return "", err
+	}
+
+	if models.WorkspaceRoleRank[role] < models.WorkspaceRoleRank[minRole] {
+		return "", errors.New("insufficient workspace role")
+	}
+
+	return role, nil
+}
+
+// resolve authorizes access to workspaceID, where 0 stands for the user's
+// personal workspace.
+func (s *WorkspaceService) resolve(ctx context.Context, workspaceID, userID int, minRole string) (int, error) {
+	if workspaceID == 0 {
+		workspace, err := s.PersonalWorkspace(ctx, userID)
+		if err != nil {
+			return 0, err
+		}
+		return workspace.ID, nil
+	}
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, minRole); err != nil {
+		return 0, err
+	}
+	return workspaceID, nil
+}
+
+// PersonalWorkspace returns the user's personal workspace, creating it on
+// first use for accounts registered after the workspaces migration.
+func (s *WorkspaceService) PersonalWorkspace(ctx context.Context, userID int) (_ *models.Workspace, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.PersonalWorkspace")
+	defer func() { tracing.End(span, err) }()
+
+	workspace, err := s.workspaceRepo.GetPersonal(ctx, userID)
+	if err == nil || err.Error() != "workspace not found" {
+		return workspace, err
+	}
+
+	workspace = &models.Workspace{Name: personalWorkspaceName, Personal: true}
+	if err := s.workspaceRepo.Create(ctx, workspace, userID); err != nil {
+		// A concurrent request may have created it first; the unique index
+		// guarantees there is only ever one
+		if existing, getErr := s.workspaceRepo.GetPersonal(ctx, userID); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+func (s *WorkspaceService) ListWorkspaces(ctx context.Context, userID int) (_ []*models.WorkspaceMembership, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.ListWorkspaces")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.PersonalWorkspace(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.workspaceRepo.GetByMember(ctx, userID)
+}
+
+func (s *WorkspaceService) CreateWorkspace(ctx context.Context, userID int, input WorkspaceInput) (_ *models.WorkspaceMembership, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.CreateWorkspace")
+	defer func() { tracing.End(span, err) }()
+
+	workspace := &models.Workspace{Name: input.Name}
+	if err := s.workspaceRepo.Create(ctx, workspace, userID); err != nil {
+		return nil, err
+	}
+
+	return &models.WorkspaceMembership{Workspace: *workspace, Role: models.WorkspaceRoleOwner}, nil
+}
+
+func (s *WorkspaceService) GetWorkspace(ctx context.Context, workspaceID, userID int) (_ *models.WorkspaceMembership, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.GetWorkspace")
+	defer func() { tracing.End(span, err) }()
+
+	role, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WorkspaceMembership{Workspace: *workspace, Role: role}, nil
+}
+
+func (s *WorkspaceService) RenameWorkspace(ctx context.Context, workspaceID, userID int, input WorkspaceInput) (_ *models.WorkspaceMembership, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.RenameWorkspace")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := s.workspaceRepo.Rename(ctx, workspaceID, input.Name); err != nil {
+		return nil, err
+	}
+
+	return s.GetWorkspace(ctx, workspaceID, userID)
+}
+
+// DeleteWorkspace removes a shared workspace together with its tasks.
+func (s *WorkspaceService) DeleteWorkspace(ctx context.Context, workspaceID, userID int) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.DeleteWorkspace")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if workspace.Personal {
+		return errors.New("personal workspace cannot be deleted")
+	}
+
+	return s.workspaceRepo.Delete(ctx, workspaceID)
+}
+
+func (s *WorkspaceService) GetMembers(ctx context.Context, workspaceID, userID int) (_ []*models.WorkspaceMember, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.GetMembers")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.workspaceRepo.GetMembers(ctx, workspaceID)
+}
+
+// UpdateMemberRole changes a member's role. Admins manage members and
+// viewers; only owners can grant or take away the owner role.
+func (s *WorkspaceService) UpdateMemberRole(ctx context.Context, workspaceID, userID, memberID int, input UpdateMemberInput) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.UpdateMemberRole")
+	defer func() { tracing.End(span, err) }()
+
+	role, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.workspaceRepo.GetMemberRole(ctx, workspaceID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if !canManage(role, current) || !canManage(role, input.Role) {
+		return errors.New("insufficient workspace role")
+	}
+
+	if current == models.WorkspaceRoleOwner && input.Role != models.WorkspaceRoleOwner {
+		if err := s.keepOwner(ctx, workspaceID); err != nil {
+			return err
+		}
+	}
+
+	return s.workspaceRepo.UpdateMemberRole(ctx, workspaceID, memberID, input.Role)
+}
+
+// RemoveMember removes memberID from the workspace. Any member may remove
+// themselves to leave it.
+func (s *WorkspaceService) RemoveMember(ctx context.Context, workspaceID, userID, memberID int) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.RemoveMember")
+	defer func() { tracing.End(span, err) }()
+
+	minRole := models.WorkspaceRoleAdmin
+	if memberID == userID {
+		minRole = models.WorkspaceRoleViewer
+	}
+
+	role, err := s.Authorize(ctx, workspaceID, userID, minRole)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.workspaceRepo.GetMemberRole(ctx, workspaceID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if memberID != userID && !canManage(role, current) {
+		return errors.New("insufficient workspace role")
+	}
+
+	if current == models.WorkspaceRoleOwner {
+		if err := s.keepOwner(ctx, workspaceID); err != nil {
+			return err
+		}
+	}
+
+	return s.workspaceRepo.RemoveMember(ctx, workspaceID, memberID, userID)
+}
+
+func (s *WorkspaceService) Invite(ctx context.Context, workspaceID, userID int, input InviteInput) (_ *models.WorkspaceInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.Invite")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.workspaceRepo.GetByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if workspace.Personal {
+		return nil, errors.New("personal workspace cannot be shared")
+	}
+
+	if invitee, err := s.userRepo.GetByEmail(ctx, input.Email); err == nil {
+		if _, err := s.workspaceRepo.GetMemberRole(ctx, workspaceID, invitee.ID); err == nil {
+			return nil, errors.New("user is already a member")
+		}
+	}
+
+	invitation := &models.WorkspaceInvitation{
+		WorkspaceID: workspaceID,
+		Email:       strings.ToLower(input.Email),
+		Role:        input.Role,
+		InvitedBy:   &userID,
+		ExpiresAt:   time.Now().Add(invitationLifetime),
+	}
+
+	if err := s.workspaceRepo.CreateInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+func (s *WorkspaceService) GetInvitations(ctx context.Context, workspaceID, userID int) (_ []*models.WorkspaceInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.GetInvitations")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.workspaceRepo.GetInvitationsByWorkspace(ctx, workspaceID)
+}
+
+func (s *WorkspaceService) RevokeInvitation(ctx context.Context, workspaceID, userID, invitationID int) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.RevokeInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.Authorize(ctx, workspaceID, userID, models.WorkspaceRoleAdmin); err != nil {
+		return err
+	}
+
+	return s.workspaceRepo.DeleteInvitation(ctx, invitationID, workspaceID)
+}
+
+// GetMyInvitations lists the pending invitations addressed to the user's
+// account email.
+func (s *WorkspaceService) GetMyInvitations(ctx context.Context, userID int) (_ []*models.WorkspaceInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.GetMyInvitations")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.workspaceRepo.GetPendingInvitationsByEmail(ctx, user.Email)
+}
+
+// AcceptInvitation joins the workspace. The invitation must have been sent
+// to the email of the accepting account.
+func (s *WorkspaceService) AcceptInvitation(ctx context.Context, invitationID, userID int) (_ *models.WorkspaceMembership, err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.AcceptInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.workspaceRepo.GetInvitation(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.AcceptedAt != nil || !strings.EqualFold(invitation.Email, user.Email) {
+		return nil, errors.New("invitation not found")
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return nil, errors.New("invitation expired")
+	}
+
+	if err := s.workspaceRepo.AcceptInvitation(ctx, invitation, userID); err != nil {
+		return nil, err
+	}
+
+	return s.GetWorkspace(ctx, invitation.WorkspaceID, userID)
+}
+
+// KeepOwners refuses to delete a user who is the last owner of a shared
+// workspace; ownership has to be handed over first. Their personal
+// workspace is deleted with them.
+func (s *WorkspaceService) KeepOwners(ctx context.Context, userID int) (err error) {
+	ctx, span := tracing.Start(ctx, "WorkspaceService.KeepOwners")
+	defer func() { tracing.End(span, err) }()
+
+	memberships, err := s.workspaceRepo.GetByMember(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, membership := range memberships {
+		if membership.Personal || membership.Role != models.WorkspaceRoleOwner {
+			continue
+		}
+		if err := s.keepOwner(ctx, membership.ID); err != nil {
+			if err.Error() == "workspace must keep an owner" {
+				return errors.New("last owner of a shared workspace")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepOwner refuses to take away the last owner of a workspace.
+func (s *WorkspaceService) keepOwner(ctx context.Context, workspaceID int) error {
+	members, err := s.workspaceRepo.GetMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	owners := 0
+	for _, member := range members {
+		if member.Role == models.WorkspaceRoleOwner {
+			owners++
+		}
+	}
+
+	if owners <= 1 {
+		return errors.New("workspace must keep an owner")
+	}
+	return nil
+}
+
+// canManage reports whether a member with role may assign or change
+// target. Only owners deal with the owner role.
+func canManage(role, target string) bool {
+	if target == models.WorkspaceRoleOwner {
+		return role == models.WorkspaceRoleOwner
+	}
+	return models.WorkspaceRoleRank[role] >= models.WorkspaceRoleRank[models.WorkspaceRoleAdmin]
+}
@@ -0,0 +1,86 @@
+// Package signing holds the HMAC keys that sign tokens, cookies and links.
+// The key can be replaced while the server runs: new signatures use the new
+// key and the previous one keeps verifying until the next rotation, so
+// sessions issued just before survive it.
+package signing
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Keys is the current signing key and the one it replaced.
+type Keys struct {
+	mu       sync.RWMutex
+	current  []byte
+	previous []byte
+
+	// derived key sets compute their keys from their parent's
+	parent *Keys
+	derive func(key []byte) []byte
+}
+
+func NewKeys(key []byte) *Keys {
+	return &Keys{current: key}
+}
+
+// Derive returns keys computed from k's with derive, such as a tenant's
+// keys from the master key. They follow k's rotations.
+func (k *Keys) Derive(derive func(key []byte) []byte) *Keys {
+	return &Keys{parent: k, derive: derive}
+}
+
+// Rotate makes key the signing key. Rotating to the current key does
+// nothing, so the previous key is not lost.
+func (k *Keys) Rotate(key []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if bytes.Equal(key, k.current) {
+		return
+	}
+	k.previous = k.current
+	k.current = key
+}
+
+// Current returns the key new signatures use.
+func (k *Keys) Current() []byte {
+	if k.parent != nil {
+		return k.derive(k.parent.Current())
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Verification returns the keys signatures are accepted with, current
+// first.
+func (k *Keys) Verification() [][]byte {
+	if k.parent != nil {
+		keys := k.parent.Verification()
+		for i, key := range keys {
+			keys[i] = k.derive(key)
+		}
+		return keys
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.previous == nil {
+		return [][]byte{k.current}
+	}
+	return [][]byte{k.current, k.previous}
+}
+
+// JWT returns the verification keys in the form jwt.Keyfunc accepts.
+func (k *Keys) JWT() jwt.VerificationKeySet {
+	keys := k.Verification()
+	set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, len(keys))}
+	for i, key := range keys {
+		set.Keys[i] = key
+	}
+	return set
+}
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- External identities (OpenID Connect) linked to local users
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+);
+
+-- Indexes
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
@@ -0,0 +1,15 @@
+-- Asynchronous personal data exports (GDPR access requests)
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed', 'failed')),
+    file_name VARCHAR(255),
+    error TEXT,
+    expires_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_data_exports_user_id ON data_exports(user_id);
+CREATE INDEX idx_data_exports_expires_at ON data_exports(expires_at);
@@ -0,0 +1,57 @@
+-- Workspaces: shared boards with per-member roles
+CREATE TABLE IF NOT EXISTS workspaces (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    -- Every user has one personal workspace, used by the /tasks routes
+    personal BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+-- Invitations are matched to the invitee by account email
+CREATE TABLE IF NOT EXISTS workspace_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member', 'viewer')),
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Move existing tasks into their owner's personal workspace
+INSERT INTO workspaces (name, personal, created_by)
+SELECT 'Personal', TRUE, id FROM users;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, created_by, 'owner' FROM workspaces WHERE personal;
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS workspace_id UUID REFERENCES workspaces(id) ON DELETE CASCADE;
+
+UPDATE tasks
+SET workspace_id = w.id
+FROM workspaces w
+WHERE w.personal AND w.created_by = tasks.user_id;
+
+ALTER TABLE tasks ALTER COLUMN workspace_id SET NOT NULL;
+
+-- Indexes
+CREATE UNIQUE INDEX idx_workspaces_personal ON workspaces(created_by) WHERE personal;
+CREATE INDEX idx_workspace_members_user_id ON workspace_members(user_id);
+CREATE INDEX idx_workspace_invitations_workspace_id ON workspace_invitations(workspace_id);
+CREATE INDEX idx_workspace_invitations_email ON workspace_invitations(lower(email));
+CREATE INDEX idx_tasks_workspace_id ON tasks(workspace_id);
+
+-- Triggers
+CREATE TRIGGER update_workspaces_updated_at BEFORE UPDATE ON workspaces
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
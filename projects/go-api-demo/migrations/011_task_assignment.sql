@@ -0,0 +1,16 @@
+-- Task assignment: one assignee per task, with the full reassignment history
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS assignee_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP;
+
+-- One row per (re)assignment; a NULL assignee records an unassignment
+CREATE TABLE IF NOT EXISTS task_assignments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id UUID NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    assigned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    assigned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_tasks_assignee_id ON tasks(assignee_id);
+CREATE INDEX idx_task_assignments_task_id ON task_assignments(task_id);
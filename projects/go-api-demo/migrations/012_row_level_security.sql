@@ -0,0 +1,61 @@
+-- Row-level security on tasks: defense in depth behind the workspace_id
+-- clauses in TaskRepository. The API switches to app_tenant and sets
+-- app.user_id at the start of each task transaction, so a query that forgets
+-- its WHERE clause still only sees the caller's workspaces.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'app_tenant') THEN
+        CREATE ROLE app_tenant NOLOGIN;
+    END IF;
+END
+$$;
+
+-- Lets the connecting role SET ROLE app_tenant even if it is not a superuser
+GRANT app_tenant TO CURRENT_USER;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON tasks, task_assignments TO app_tenant;
+
+-- The acting user, or NULL when none was set (which matches no rows)
+CREATE OR REPLACE FUNCTION app_current_user_id() RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('app.user_id', true), '')::uuid;
+$$ LANGUAGE sql STABLE;
+
+-- Workspaces the acting user belongs to; with writable, only those where
+-- their role lets them change tasks. SECURITY DEFINER so app_tenant needs
+-- no access to workspace_members itself.
+CREATE OR REPLACE FUNCTION app_workspace_ids(writable BOOLEAN) RETURNS SETOF UUID AS $$
+    SELECT workspace_id
+    FROM workspace_members
+    WHERE user_id = app_current_user_id()
+      AND (NOT writable OR role <> 'viewer');
+$$ LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public;
+
+GRANT EXECUTE ON FUNCTION app_current_user_id(), app_workspace_ids(BOOLEAN) TO app_tenant;
+
+-- FORCE makes the policies apply to the table owner as well
+ALTER TABLE tasks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tasks FORCE ROW LEVEL SECURITY;
+ALTER TABLE task_assignments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE task_assignments FORCE ROW LEVEL SECURITY;
+
+-- Members read their workspaces' tasks; creators keep reading what they
+-- wrote (needed for their data export) after leaving a workspace
+CREATE POLICY tasks_select ON tasks FOR SELECT
+    USING (workspace_id IN (SELECT app_workspace_ids(false)) OR user_id = app_current_user_id());
+
+CREATE POLICY tasks_insert ON tasks FOR INSERT
+    WITH CHECK (workspace_id IN (SELECT app_workspace_ids(true)));
+
+CREATE POLICY tasks_update ON tasks FOR UPDATE
+    USING (workspace_id IN (SELECT app_workspace_ids(true)))
+    WITH CHECK (workspace_id IN (SELECT app_workspace_ids(true)));
+
+CREATE POLICY tasks_delete ON tasks FOR DELETE
+    USING (workspace_id IN (SELECT app_workspace_ids(true)));
+
+-- Assignment history follows the visibility of its task
+CREATE POLICY task_assignments_select ON task_assignments FOR SELECT
+    USING (task_id IN (SELECT id FROM tasks));
+
+CREATE POLICY task_assignments_insert ON task_assignments FOR INSERT
+    WITH CHECK (task_id IN (SELECT id FROM tasks WHERE workspace_id IN (SELECT app_workspace_ids(true))));
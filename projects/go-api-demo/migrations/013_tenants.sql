@@ -0,0 +1,122 @@
+-- Multi-tenancy. The tenant registry always lives in public; the rest of
+-- this file also runs inside each tenant schema in schema-per-tenant mode.
+CREATE TABLE IF NOT EXISTS public.tenants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(63) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended')),
+    -- Set in schema-per-tenant mode only
+    schema_name VARCHAR(63) UNIQUE,
+    -- Quotas; NULL means unlimited
+    max_users INTEGER,
+    max_tasks INTEGER,
+    -- Requests per minute and IP; 0 uses RATE_LIMIT_PER_MINUTE
+    rate_limit INTEGER NOT NULL DEFAULT 0,
+    -- Kept by triggers so quotas are enforced atomically
+    user_count INTEGER NOT NULL DEFAULT 0,
+    task_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Owns everything that existed before multi-tenancy
+INSERT INTO public.tenants (slug, name)
+VALUES ('default', 'Default')
+ON CONFLICT (slug) DO NOTHING;
+
+-- The tenant of the current connection (set by ConnectTenantDB), or the
+-- default tenant in single-tenant deployments
+CREATE OR REPLACE FUNCTION public.app_current_tenant_id() RETURNS UUID AS $$
+    SELECT COALESCE(
+        NULLIF(current_setting('app.tenant_id', true), '')::uuid,
+        (SELECT id FROM public.tenants WHERE slug = 'default')
+    );
+$$ LANGUAGE sql STABLE SECURITY DEFINER SET search_path = public;
+
+GRANT EXECUTE ON FUNCTION public.app_current_tenant_id() TO PUBLIC;
+
+-- In schema-per-tenant mode the membership lookup behind the task policies
+-- must read the tenant's own schema, which is the search path this file
+-- runs with
+ALTER FUNCTION app_workspace_ids(BOOLEAN) SET search_path FROM CURRENT;
+
+-- A tenant_id on every table, filled in from the connection, with an index
+-- and a row-level security policy keeping each tenant to its own rows
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'users', 'tasks', 'recovery_codes', 'personal_access_tokens',
+        'login_attempts', 'audit_events', 'user_identities', 'oauth_clients',
+        'oauth_authorization_codes', 'oauth_access_tokens', 'data_exports',
+        'workspaces', 'workspace_members', 'workspace_invitations',
+        'task_assignments'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS tenant_id UUID', t);
+        EXECUTE format('UPDATE %I SET tenant_id = public.app_current_tenant_id() WHERE tenant_id IS NULL', t);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET DEFAULT public.app_current_tenant_id()', t);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET NOT NULL', t);
+        EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I FOREIGN KEY (tenant_id) REFERENCES public.tenants(id) ON DELETE CASCADE', t, t || '_tenant_id_fkey');
+        EXECUTE format('CREATE INDEX %I ON %I(tenant_id)', 'idx_' || t || '_tenant_id', t);
+
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        -- Tasks already have per-user policies; the tenant check narrows them
+        EXECUTE format(
+            'CREATE POLICY tenant_isolation ON %I AS %s USING (tenant_id = public.app_current_tenant_id()) WITH CHECK (tenant_id = public.app_current_tenant_id())',
+            t, CASE WHEN t IN ('tasks', 'task_assignments') THEN 'RESTRICTIVE' ELSE 'PERMISSIVE' END
+        );
+    END LOOP;
+END
+$$;
+
+-- Uniqueness is per tenant
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX idx_users_tenant_email ON users(tenant_id, email);
+
+ALTER TABLE user_identities DROP CONSTRAINT IF EXISTS user_identities_provider_subject_key;
+CREATE UNIQUE INDEX idx_user_identities_tenant_subject ON user_identities(tenant_id, provider, subject);
+
+ALTER TABLE login_attempts DROP CONSTRAINT IF EXISTS login_attempts_pkey;
+ALTER TABLE login_attempts ADD PRIMARY KEY (tenant_id, key);
+
+-- Quotas: reserve a slot in the tenant's counter before each insert and
+-- release it on delete. The row lock on the tenant serializes concurrent
+-- inserts, so the limit cannot be overshot.
+CREATE OR REPLACE FUNCTION public.app_reserve_quota() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        EXECUTE format('UPDATE public.tenants SET %I = %I - 1 WHERE id = $1', TG_ARGV[0], TG_ARGV[0])
+            USING OLD.tenant_id;
+        RETURN OLD;
+    END IF;
+
+    EXECUTE format(
+        'UPDATE public.tenants SET %I = %I + 1 WHERE id = $1 AND (%I IS NULL OR %I < %I)',
+        TG_ARGV[0], TG_ARGV[0], TG_ARGV[1], TG_ARGV[0], TG_ARGV[1]
+    ) USING NEW.tenant_id;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION '% quota exceeded', TG_ARGV[2] USING ERRCODE = 'TQ001';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER SET search_path = public;
+
+CREATE TRIGGER reserve_user_quota BEFORE INSERT ON users
+    FOR EACH ROW EXECUTE FUNCTION public.app_reserve_quota('user_count', 'max_users', 'user');
+CREATE TRIGGER release_user_quota AFTER DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION public.app_reserve_quota('user_count', 'max_users', 'user');
+CREATE TRIGGER reserve_task_quota BEFORE INSERT ON tasks
+    FOR EACH ROW EXECUTE FUNCTION public.app_reserve_quota('task_count', 'max_tasks', 'task');
+CREATE TRIGGER release_task_quota AFTER DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION public.app_reserve_quota('task_count', 'max_tasks', 'task');
+
+-- Start the counters from the rows already present
+UPDATE public.tenants t
+SET user_count = t.user_count + (SELECT COUNT(*) FROM users u WHERE u.tenant_id = t.id),
+    task_count = t.task_count + (SELECT COUNT(*) FROM tasks k WHERE k.tenant_id = t.id);
+
+DROP TRIGGER IF EXISTS update_tenants_updated_at ON public.tenants;
+CREATE TRIGGER update_tenants_updated_at BEFORE UPDATE ON public.tenants
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
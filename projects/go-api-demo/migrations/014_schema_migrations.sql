@@ -0,0 +1,12 @@
+-- Applied migrations. Each migration from here on ends by recording its
+-- number, and /readyz checks the highest one against the version the code
+-- expects (repository.SchemaVersion).
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Everything up to here was applied before the table existed
+INSERT INTO schema_migrations (version)
+SELECT generate_series(1, 14)
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Time step of the last TOTP code accepted, so each code is used only once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_counter BIGINT;
+
+INSERT INTO schema_migrations (version) VALUES (15);
@@ -0,0 +1,7 @@
+-- Tasks in shared workspaces outlive an erased creator: the creator is
+-- cleared instead of deleting their work for everyone else
+ALTER TABLE tasks ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS tasks_user_id_fkey;
+ALTER TABLE tasks ADD CONSTRAINT tasks_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+INSERT INTO schema_migrations (version) VALUES (16);